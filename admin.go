@@ -0,0 +1,235 @@
+package alog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AdminHandler returns a http.Handler to inspect and change the level settings of the logger.
+//
+// GET responds the current settings as JSON. PUT and POST change the settings given by a JSON body,
+// or by a form for convenience of curl, e.g. `curl -X PUT 'localhost:6060/log?level=debug&ttl=5m'`.
+// Fields absent from the request are left unchanged:
+//
+//	{"level": "debug", "levels": "svc.db.*=debug", "verbose": 2, "vmodule": "server=3", "ttl": "5m"}
+//
+// If ttl is set, the change is temporary and reverts automatically after the ttl.
+// A temporary change made during another one extends the ttl and still reverts to the settings
+// before the first one, and a permanent change made during a temporary one is kept after the revert.
+func AdminHandler(logger types.Logger) http.Handler {
+	return &adminHandler{logger: logger}
+}
+
+type adminHandler struct {
+	logger types.Logger
+
+	mutex     sync.Mutex
+	timer     *time.Timer
+	expiresAt time.Time
+	saved     adminSettings
+}
+
+// adminSettings is both the request and the response of adminHandler.
+type adminSettings struct {
+	Level     *string    `json:"level,omitempty"`
+	Levels    *string    `json:"levels,omitempty"`
+	Verbose   *int       `json:"verbose,omitempty"`
+	VModule   *string    `json:"vmodule,omitempty"`
+	TTL       string     `json:"ttl,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+
+		h.respond(w, http.StatusOK, h.current())
+	case http.MethodPut, http.MethodPost:
+		req, ttl, err := decodeAdminRequest(r)
+		if err != nil {
+			h.respond(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+
+		if err := h.change(req, ttl); err != nil {
+			h.respond(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		h.respond(w, http.StatusOK, h.current())
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		h.respond(w, http.StatusMethodNotAllowed, map[string]string{"error": "only GET, PUT and POST are supported"})
+	}
+}
+
+func decodeAdminRequest(r *http.Request) (adminSettings, time.Duration, error) {
+	var req adminSettings
+	if r.URL.RawQuery != "" || strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if err := r.ParseForm(); err != nil {
+			return req, 0, fmt.Errorf("parse form failed, %w", err)
+		}
+		for key, field := range map[string]**string{"level": &req.Level, "levels": &req.Levels, "vmodule": &req.VModule} {
+			if values, ok := r.Form[key]; ok {
+				*field = &values[0]
+			}
+		}
+		if values, ok := r.Form["verbose"]; ok {
+			verbose, err := strconv.Atoi(values[0])
+			if err != nil {
+				return req, 0, fmt.Errorf("bad verbose `%s`", values[0])
+			}
+			req.Verbose = &verbose
+		}
+		req.TTL = r.Form.Get("ttl")
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, 0, fmt.Errorf("decode request failed, %w", err)
+	}
+
+	if req.Level == nil && req.Levels == nil && req.Verbose == nil && req.VModule == nil {
+		return req, 0, errors.New("nothing to change, expect level, levels, verbose or vmodule")
+	}
+	if req.Level != nil {
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(*req.Level)); err != nil {
+			return req, 0, fmt.Errorf("bad level `%s`, %w", *req.Level, err)
+		}
+	}
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			return req, 0, fmt.Errorf("bad ttl `%s`, expect a positive duration", req.TTL)
+		}
+	}
+	return req, ttl, nil
+}
+
+// change applies the settings, all of them are rolled back if any fails.
+func (h *adminHandler) change(req adminSettings, ttl time.Duration) error {
+	before := h.current()
+	if err := h.apply(req); err != nil {
+		if rollbackErr := h.apply(before); rollbackErr != nil {
+			err = fmt.Errorf("%w, rollback failed, %v", err, rollbackErr)
+		}
+		return err
+	}
+
+	switch {
+	case ttl > 0 && h.timer == nil:
+		h.saved = before
+		h.schedule(ttl)
+	case ttl > 0:
+		h.timer.Stop()
+		h.schedule(ttl)
+	case h.timer != nil:
+		// keep the permanent change after the revert
+		if req.Level != nil {
+			h.saved.Level = req.Level
+		}
+		if req.Levels != nil {
+			h.saved.Levels = req.Levels
+		}
+		if req.Verbose != nil {
+			h.saved.Verbose = req.Verbose
+		}
+		if req.VModule != nil {
+			h.saved.VModule = req.VModule
+		}
+	}
+	return nil
+}
+
+func (h *adminHandler) schedule(ttl time.Duration) {
+	var timer *time.Timer
+	timer = time.AfterFunc(ttl, func() {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+
+		// the timer was replaced while waiting for the lock
+		if h.timer != timer {
+			return
+		}
+		h.timer = nil
+		h.expiresAt = time.Time{}
+		if err := h.apply(h.saved); err != nil {
+			h.logger.Error("revert temporary log settings failed", zap.Error(err))
+		}
+	})
+	h.timer = timer
+	h.expiresAt = time.Now().Add(ttl)
+}
+
+func (h *adminHandler) apply(s adminSettings) error {
+	if s.Levels != nil {
+		reloader, ok := h.logger.(types.LogLevelsHotReloader)
+		if !ok {
+			return fmt.Errorf("reload levels failed, %w", types.ErrUnsupported)
+		}
+		if err := reloader.HotReloadLogLevels(*s.Levels); err != nil {
+			return fmt.Errorf("reload levels failed, %w", err)
+		}
+	}
+	if s.VModule != nil {
+		reloader, ok := h.logger.(types.LogVModuleHotReloader)
+		if !ok {
+			return fmt.Errorf("reload vmodule failed, %w", types.ErrUnsupported)
+		}
+		if err := reloader.HotReloadLogVModule(*s.VModule); err != nil {
+			return fmt.Errorf("reload vmodule failed, %w", err)
+		}
+	}
+	if s.Level != nil {
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(*s.Level)); err != nil {
+			return fmt.Errorf("bad level `%s`, %w", *s.Level, err)
+		}
+		if err := h.logger.HotReloadLogLevel(level); err != nil {
+			return fmt.Errorf("reload level failed, %w", err)
+		}
+	}
+	if s.Verbose != nil {
+		if err := h.logger.HostReloadLogVerbose(*s.Verbose); err != nil {
+			return fmt.Errorf("reload verbose failed, %w", err)
+		}
+	}
+	return nil
+}
+
+// current returns the settings of the logger, which are empty if it does not implement types.LogLevelInspector.
+func (h *adminHandler) current() adminSettings {
+	var s adminSettings
+	if inspector, ok := h.logger.(types.LogLevelInspector); ok {
+		level := inspector.LogLevel().String()
+		levels := inspector.LogLevels()
+		verbose := inspector.LogVerbose()
+		vmodule := inspector.LogVModule()
+		s = adminSettings{Level: &level, Levels: &levels, Verbose: &verbose, VModule: &vmodule}
+	}
+	if h.timer != nil {
+		expiresAt := h.expiresAt
+		s.ExpiresAt = &expiresAt
+	}
+	return s
+}
+
+func (h *adminHandler) respond(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
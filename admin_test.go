@@ -0,0 +1,114 @@
+package alog_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/csh0101/alog"
+	"github.com/csh0101/alog/options"
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type adminSettings struct {
+	Level     string     `json:"level"`
+	Levels    string     `json:"levels"`
+	Verbose   int        `json:"verbose"`
+	VModule   string     `json:"vmodule"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	Error     string     `json:"error"`
+}
+
+func TestAdminHandler(t *testing.T) {
+	logger, err := alog.NewLogger("svc", options.WithWriter(io.Discard), options.WithLogLevels("svc.db=debug"))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	server := httptest.NewServer(alog.AdminHandler(logger))
+	defer server.Close()
+
+	s := doAdminRequest(t, http.MethodGet, server.URL, "", http.StatusOK)
+	if s.Level != "info" || s.Levels != "svc.db=debug" || s.Verbose != 0 || s.VModule != "" || s.ExpiresAt != nil {
+		t.Fatalf("unexpected settings %+v", s)
+	}
+
+	s = doAdminRequest(t, http.MethodPut, server.URL, `{"level":"warn","verbose":2,"vmodule":"server=3"}`, http.StatusOK)
+	if s.Level != "warn" || s.Verbose != 2 || s.VModule != "server=3" || s.Levels != "svc.db=debug" {
+		t.Fatalf("unexpected settings %+v", s)
+	}
+	if logger.Enabled(zapcore.InfoLevel) || logger.(types.LogLevelInspector).LogVerbose() != 2 {
+		t.Fatal("settings are not applied to the logger")
+	}
+
+	s = doAdminRequest(t, http.MethodPost, server.URL+"?levels=svc.http%3Derror", "", http.StatusOK)
+	if s.Levels != "svc.http=error" {
+		t.Fatalf("unexpected settings %+v", s)
+	}
+
+	// bad requests change nothing
+	for _, body := range []string{`{}`, `{"level":"loud"}`, `{"verbose":1,"vmodule":"[=1"}`, `{"level":"debug","ttl":"-1s"}`} {
+		s = doAdminRequest(t, http.MethodPut, server.URL, body, http.StatusBadRequest)
+		if s.Error == "" {
+			t.Fatalf("error is missing for %s", body)
+		}
+	}
+	if logger.(types.LogLevelInspector).LogVerbose() != 2 || logger.(types.LogLevelInspector).LogVModule() != "server=3" {
+		t.Fatal("settings must be rolled back on failure")
+	}
+	doAdminRequest(t, http.MethodDelete, server.URL, "", http.StatusMethodNotAllowed)
+}
+
+func TestAdminHandler_TTL(t *testing.T) {
+	logger, err := alog.NewLogger("svc", options.WithWriter(io.Discard))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	server := httptest.NewServer(alog.AdminHandler(logger))
+	defer server.Close()
+
+	s := doAdminRequest(t, http.MethodPut, server.URL, `{"level":"debug","verbose":3,"ttl":"100ms"}`, http.StatusOK)
+	if s.Level != "debug" || s.Verbose != 3 || s.ExpiresAt == nil {
+		t.Fatalf("unexpected settings %+v", s)
+	}
+	// the permanent change is kept after the revert
+	doAdminRequest(t, http.MethodPut, server.URL, `{"vmodule":"server=1"}`, http.StatusOK)
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if s = doAdminRequest(t, http.MethodGet, server.URL, "", http.StatusOK); s.ExpiresAt == nil {
+			break
+		}
+	}
+	if s.Level != "info" || s.Verbose != 0 || s.VModule != "server=1" || s.ExpiresAt != nil {
+		t.Fatalf("temporary change is not reverted, got %+v", s)
+	}
+}
+
+func doAdminRequest(t *testing.T, method, url, body string, code int) adminSettings {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("new request failed, %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("do request failed, %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != code {
+		b, _ := io.ReadAll(resp.Body)
+		t.Fatalf("%s %s: expected status %d, got %d, %s", method, body, code, resp.StatusCode, b)
+	}
+	var s adminSettings
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		t.Fatalf("decode response failed, %v", err)
+	}
+	return s
+}
@@ -0,0 +1,162 @@
+// Package agrpc provides a grpclog.LoggerV2 on top of alog, for the internal logs of gRPC.
+package agrpc
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/grpclog"
+)
+
+// depthSkip is the skip of runtime.Callers in entry for the caller at depth 0,
+// which is the caller of the package functions of grpclog, e.g. grpclog.Info and grpclog.InfoDepth.
+const depthSkip = 5
+
+var (
+	_ grpclog.LoggerV2      = (*Logger)(nil)
+	_ grpclog.DepthLoggerV2 = (*Logger)(nil)
+)
+
+// Logger implements grpclog.LoggerV2 and grpclog.DepthLoggerV2 by an alog logger.
+//
+// V(l) follows the verbose and its hot reloads of the logger.
+// Logs of a gRPC component, e.g. `[core]`, are logged by the child Named of the component.
+type Logger struct {
+	logger     types.Logger
+	components sync.Map
+}
+
+// New returns a gRPC logger writing the logs by logger, install it by grpclog.SetLoggerV2.
+func New(logger types.Logger) *Logger {
+	return &Logger{logger: logger}
+}
+
+func (l *Logger) Info(args ...interface{}) {
+	l.log(zapcore.InfoLevel, 0, fmt.Sprint(args...))
+}
+
+func (l *Logger) Infoln(args ...interface{}) {
+	l.log(zapcore.InfoLevel, 0, sprintln(args))
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.log(zapcore.InfoLevel, 0, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Warning(args ...interface{}) {
+	l.log(zapcore.WarnLevel, 0, fmt.Sprint(args...))
+}
+
+func (l *Logger) Warningln(args ...interface{}) {
+	l.log(zapcore.WarnLevel, 0, sprintln(args))
+}
+
+func (l *Logger) Warningf(format string, args ...interface{}) {
+	l.log(zapcore.WarnLevel, 0, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Error(args ...interface{}) {
+	l.log(zapcore.ErrorLevel, 0, fmt.Sprint(args...))
+}
+
+func (l *Logger) Errorln(args ...interface{}) {
+	l.log(zapcore.ErrorLevel, 0, sprintln(args))
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.log(zapcore.ErrorLevel, 0, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Fatal(args ...interface{}) {
+	l.log(zapcore.FatalLevel, 0, fmt.Sprint(args...))
+}
+
+func (l *Logger) Fatalln(args ...interface{}) {
+	l.log(zapcore.FatalLevel, 0, sprintln(args))
+}
+
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.log(zapcore.FatalLevel, 0, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) V(level int) bool {
+	return l.logger.V(level).Enabled(zapcore.InfoLevel)
+}
+
+func (l *Logger) InfoDepth(depth int, args ...interface{}) {
+	l.logDepth(zapcore.InfoLevel, depth, args)
+}
+
+func (l *Logger) WarningDepth(depth int, args ...interface{}) {
+	l.logDepth(zapcore.WarnLevel, depth, args)
+}
+
+func (l *Logger) ErrorDepth(depth int, args ...interface{}) {
+	l.logDepth(zapcore.ErrorLevel, depth, args)
+}
+
+func (l *Logger) FatalDepth(depth int, args ...interface{}) {
+	l.logDepth(zapcore.FatalLevel, depth, args)
+}
+
+// logDepth must be called by the methods of grpclog.DepthLoggerV2 directly, to find the caller by depth.
+func (l *Logger) logDepth(level zapcore.Level, depth int, args []interface{}) {
+	logger, args := l.component(args)
+	logEntry(logger, entry(level, depth, sprintln(args)))
+}
+
+// log must be called by the methods of grpclog.LoggerV2 directly, to find the caller by depth.
+func (l *Logger) log(level zapcore.Level, depth int, msg string) {
+	logEntry(l.logger, entry(level, depth, msg))
+}
+
+// logEntry logs the fatal entries by the fatal path of the logger, which exits as Fatal does.
+func logEntry(logger types.Logger, ent zapcore.Entry) {
+	if ent.Level == zapcore.FatalLevel {
+		azap.LogFatalEntry(logger, ent)
+		return
+	}
+	azap.LogEntry(logger, ent)
+}
+
+// component returns the child logger of the component, if the first argument is a component prefix like `[core]`.
+func (l *Logger) component(args []interface{}) (types.Logger, []interface{}) {
+	if len(args) == 0 {
+		return l.logger, args
+	}
+	prefix, ok := args[0].(string)
+	if !ok || len(prefix) < 3 || prefix[0] != '[' || prefix[len(prefix)-1] != ']' {
+		return l.logger, args
+	}
+	name := prefix[1 : len(prefix)-1]
+	if logger, ok := l.components.Load(name); ok {
+		return logger.(types.Logger), args[1:]
+	}
+	logger, _ := l.components.LoadOrStore(name, l.logger.Named(name))
+	return logger.(types.Logger), args[1:]
+}
+
+func entry(level zapcore.Level, depth int, msg string) zapcore.Entry {
+	ent := zapcore.Entry{
+		Level:   level,
+		Time:    time.Now(),
+		Message: msg,
+	}
+	var pcs [1]uintptr
+	if runtime.Callers(depthSkip+depth, pcs[:]) > 0 {
+		frame, _ := runtime.CallersFrames(pcs[:]).Next()
+		ent.Caller = zapcore.NewEntryCaller(pcs[0], frame.File, frame.Line, true)
+	}
+	return ent
+}
+
+func sprintln(args []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
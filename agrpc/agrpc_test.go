@@ -0,0 +1,77 @@
+package agrpc_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/csh0101/alog/agrpc"
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+func TestLogger(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := azap.NewLogger("grpc", options.WithWriter(buf))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	grpclog.SetLoggerV2(agrpc.New(logger))
+
+	grpclog.Infof("hello %s", "grpc")
+	grpclog.Component("core").Warningf("channel %d idle", 1)
+	grpclog.Component("transport").Errorln("conn", "closed")
+	if grpclog.V(2) {
+		t.Fatal("V(2) must be disabled by default")
+	}
+	if err := logger.HostReloadLogVerbose(2); err != nil {
+		t.Fatalf("reload verbose failed, %v", err)
+	}
+	if !grpclog.V(2) || grpclog.V(3) {
+		t.Fatal("V does not follow the verbose")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := [][]string{
+		{`"level":"INFO"`, `"logger":"grpc"`, `"msg":"hello grpc"`},
+		{`"level":"WARN"`, `"logger":"grpc.core"`, `"msg":"channel 1 idle"`},
+		{`"level":"ERROR"`, `"logger":"grpc.transport"`, `"msg":"conn closed"`},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %s", len(expected), buf.String())
+	}
+	for i, line := range lines {
+		for _, s := range append(expected[i], "agrpc/agrpc_test.go") {
+			if !strings.Contains(line, s) {
+				t.Fatalf("`%s` is missing, got %s", s, line)
+			}
+		}
+	}
+}
+
+func TestLogger_FatalDepth(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := azap.NewLogger("grpc", options.WithWriter(buf), options.WithFatalPanic())
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	grpcLogger := agrpc.New(logger)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("FatalDepth must run the fatal action")
+			}
+		}()
+		grpcLogger.FatalDepth(0, "[core]", "conn", "lost")
+	}()
+
+	line := strings.TrimSpace(buf.String())
+	for _, s := range []string{`"level":"FATAL"`, `"logger":"grpc.core"`, `"msg":"conn lost"`, "agrpc/agrpc_test.go"} {
+		if !strings.Contains(line, s) {
+			t.Fatalf("`%s` is missing, got %s", s, line)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Package alogr provides a go-logr LogSink on top of alog, e.g. for the logs of controller-runtime.
+package alogr
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/types"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// New returns a logr.Logger writing the logs by logger.
+func New(logger types.Logger) logr.Logger {
+	return logr.New(NewLogSink(logger))
+}
+
+// NewLogSink returns a logr.LogSink writing the logs by logger.
+//
+//   - V(n).Info is V(n).Info of alog, filtered by the verbose and the vmodule rules of the logger,
+//   - Error is ERROR with the `error` field, regardless of V(n) as logr requires,
+//   - WithName is Named, and WithValues adds the key-value pairs as fields.
+func NewLogSink(logger types.Logger) logr.LogSink {
+	return &logSink{logger: logger}
+}
+
+var (
+	_ logr.LogSink          = (*logSink)(nil)
+	_ logr.CallDepthLogSink = (*logSink)(nil)
+)
+
+type logSink struct {
+	logger types.Logger
+	// callDepth is the number of frames between the caller and the methods of logSink
+	callDepth int
+}
+
+func (s *logSink) Init(info logr.RuntimeInfo) {
+	s.callDepth = info.CallDepth
+}
+
+func (s *logSink) Enabled(level int) bool {
+	return azap.EntryEnabled(s.verbose(level), s.entry(zapcore.InfoLevel, ""))
+}
+
+func (s *logSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	azap.LogEntry(s.verbose(level), s.entry(zapcore.InfoLevel, msg), fields(keysAndValues)...)
+}
+
+func (s *logSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	azap.LogEntry(s.logger, s.entry(zapcore.ErrorLevel, msg), append(fields(keysAndValues), zap.Error(err))...)
+}
+
+func (s *logSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &logSink{
+		logger:    s.logger.With(fields(keysAndValues)...),
+		callDepth: s.callDepth,
+	}
+}
+
+func (s *logSink) WithName(name string) logr.LogSink {
+	return &logSink{
+		logger:    s.logger.Named(name),
+		callDepth: s.callDepth,
+	}
+}
+
+func (s *logSink) WithCallDepth(depth int) logr.LogSink {
+	return &logSink{
+		logger:    s.logger,
+		callDepth: s.callDepth + depth,
+	}
+}
+
+func (s *logSink) verbose(level int) types.Logger {
+	if level <= 0 {
+		return s.logger
+	}
+	return s.logger.V(level)
+}
+
+// entry must be called by the methods of logr.LogSink directly, to find the caller by callDepth.
+func (s *logSink) entry(level zapcore.Level, msg string) zapcore.Entry {
+	ent := zapcore.Entry{
+		Level:   level,
+		Time:    time.Now(),
+		Message: msg,
+	}
+	var pcs [1]uintptr
+	if runtime.Callers(s.callDepth+3, pcs[:]) > 0 {
+		frame, _ := runtime.CallersFrames(pcs[:]).Next()
+		ent.Caller = zapcore.NewEntryCaller(pcs[0], frame.File, frame.Line, true)
+	}
+	return ent
+}
+
+// fields converts the key-value pairs into fields, the values implementing logr.Marshaler are marshaled.
+func fields(keysAndValues []interface{}) []zapcore.Field {
+	copied := false
+	for i, v := range keysAndValues {
+		m, ok := v.(logr.Marshaler)
+		if !ok {
+			continue
+		}
+		// the pairs belong to the caller
+		if !copied {
+			keysAndValues, copied = append([]interface{}(nil), keysAndValues...), true
+		}
+		keysAndValues[i] = m.MarshalLog()
+	}
+	return azap.Fields(keysAndValues...)
+}
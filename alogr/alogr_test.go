@@ -0,0 +1,64 @@
+package alogr_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/csh0101/alog/alogr"
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+)
+
+type objectRef struct {
+	namespace, name string
+}
+
+func (r objectRef) MarshalLog() interface{} {
+	return r.namespace + "/" + r.name
+}
+
+func TestLogSink(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := azap.NewLogger("manager", options.WithWriter(buf), options.WithVModule("alogr_test=3"))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	log := alogr.New(logger).WithName("controller").WithValues("pod", objectRef{"default", "web"})
+	log.Info("reconciling", "generation", 2)
+	log.V(2).Info("v2")
+	log.V(4).Info("v4")
+	log.V(4).Error(errors.New("boom"), "reconcile failed")
+	if !log.V(3).Enabled() || log.V(4).Enabled() {
+		t.Fatal("Enabled does not follow the vmodule rules")
+	}
+
+	if err := logger.HotReloadLogVModule(""); err != nil {
+		t.Fatalf("reload vmodule failed, %v", err)
+	}
+	log.V(2).Info("v2-after-reload")
+	if err := logger.HostReloadLogVerbose(2); err != nil {
+		t.Fatalf("reload verbose failed, %v", err)
+	}
+	log.V(2).Info("v2-after-verbose")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := [][]string{
+		{`"level":"INFO"`, `"msg":"reconciling"`, `"generation":2`},
+		{`"level":"INFO"`, `"msg":"v2"`},
+		{`"level":"ERROR"`, `"msg":"reconcile failed"`, `"error":"boom"`},
+		{`"level":"INFO"`, `"msg":"v2-after-verbose"`},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %s", len(expected), buf.String())
+	}
+	for i, line := range lines {
+		for _, s := range append(expected[i], `"logger":"manager.controller"`, `"pod":"default/web"`, "alogr/alogr_test.go") {
+			if !strings.Contains(line, s) {
+				t.Fatalf("`%s` is missing, got %s", s, line)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Package alogtest provides a logger for unit tests which records structured entries
+// and mirrors them to the test log.
+package alogtest
+
+import (
+	"bytes"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// TestingT is the subset of testing.TB used by the test logger.
+type TestingT interface {
+	Name() string
+	Logf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+	Helper()
+}
+
+// Logger is a types.Logger which records every entry it writes.
+// Entries logged through V(), Named() and azap.Ctx wrappers of it are recorded as well.
+type Logger struct {
+	azap.ZapLogger
+	*ObservedLogs
+}
+
+// NewLogger new a test logger named after the test.
+//
+//   - default log level :  DEBUG
+//   - default output to :  t.Log
+//   - default encoding  :  console
+//
+// Options are applied after the defaults, so they can override them.
+func NewLogger(t TestingT, opts ...options.LoggerOption) *Logger {
+	t.Helper()
+
+	logs := &ObservedLogs{t: t}
+	defaults := []options.LoggerOption{
+		options.WithLogLevel(zapcore.DebugLevel),
+		options.WithWriter(testingWriter{t: t}),
+		options.WithStructuredFormat(false),
+		options.WithWrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, &observerCore{LevelEnabler: core, logs: logs})
+		}),
+	}
+
+	logger, err := azap.NewLogger(t.Name(), append(defaults, opts...)...)
+	if err != nil {
+		t.Fatalf("new test logger failed, %v", err)
+		return nil
+	}
+	return &Logger{
+		ZapLogger:    logger,
+		ObservedLogs: logs,
+	}
+}
+
+// testingWriter mirrors encoded entries to the test log.
+type testingWriter struct {
+	t TestingT
+}
+
+func (w testingWriter) Write(b []byte) (int, error) {
+	w.t.Logf("%s", bytes.TrimRight(b, "\n"))
+	return len(b), nil
+}
@@ -0,0 +1,115 @@
+package alogtest_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/csh0101/alog/alogtest"
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogger_RecordEntries(t *testing.T) {
+	logger := alogtest.NewLogger(t)
+
+	logger.Debug("debug msg", zap.String("hello", "debug"))
+	logger.Info("info msg", zap.Int("count", 3))
+	logger.Named("sub").Warn("warn msg")
+
+	if logger.Len() != 3 {
+		t.Fatalf("expected 3 entries, got %d", logger.Len())
+	}
+
+	entry := logger.RequireEntry("info msg", zap.Int("count", 3))
+	if entry.Level != zapcore.InfoLevel {
+		t.Fatalf("expected level INFO, got %s", entry.Level)
+	}
+	if entry.LoggerName != t.Name() {
+		t.Fatalf("expected logger name %q, got %q", t.Name(), entry.LoggerName)
+	}
+	if !entry.Caller.Defined || filepath.Base(entry.Caller.File) != "alogtest_test.go" {
+		t.Fatalf("bad caller %v", entry.Caller)
+	}
+	if entry.ContextMap()["count"] != int64(3) {
+		t.Fatalf("bad context map %v", entry.ContextMap())
+	}
+
+	if n := logger.FilterLoggerName(t.Name() + ".sub").Len(); n != 1 {
+		t.Fatalf("expected 1 entry from named logger, got %d", n)
+	}
+	if n := logger.FilterField(zap.String("hello", "debug")).Len(); n != 1 {
+		t.Fatalf("expected 1 entry with field, got %d", n)
+	}
+	logger.RequireNoEntry("error msg")
+}
+
+func TestLogger_LogLevel(t *testing.T) {
+	logger := alogtest.NewLogger(t, options.WithLogLevel(zapcore.WarnLevel))
+
+	logger.Info("info msg")
+	logger.Warn("warn msg")
+
+	logger.RequireNoEntry("info msg")
+	logger.RequireEntry("warn msg")
+}
+
+func TestLogger_Verbose(t *testing.T) {
+	logger := alogtest.NewLogger(t, options.WithVerboseFilter(1))
+
+	logger.V(1).Info("v1 msg")
+	logger.V(2).Info("v2 msg")
+
+	entry := logger.RequireEntry("v1 msg")
+	if filepath.Base(entry.Caller.File) != "alogtest_test.go" {
+		t.Fatalf("bad caller %v", entry.Caller)
+	}
+	logger.RequireNoEntry("v2 msg")
+}
+
+func TestLogger_Ctx(t *testing.T) {
+	logger := alogtest.NewLogger(t)
+	ctx := context.WithValue(context.Background(), "request_id", "123456")
+
+	azap.Ctx(ctx, logger).Info("ctx msg")
+
+	logger.RequireEntry("ctx msg", zap.String("request_id", "123456"))
+}
+
+func TestLogger_TakeAll(t *testing.T) {
+	logger := alogtest.NewLogger(t)
+
+	logger.Info("info msg")
+	if n := len(logger.TakeAll()); n != 1 {
+		t.Fatalf("expected 1 entry, got %d", n)
+	}
+	if logger.Len() != 0 {
+		t.Fatalf("expected no entries after TakeAll, got %d", logger.Len())
+	}
+}
+
+func TestLogger_RequireEntryFailure(t *testing.T) {
+	ft := &fakeT{name: t.Name()}
+	logger := alogtest.NewLogger(ft)
+
+	logger.Info("info msg", zap.String("hello", "info"))
+	logger.RequireEntry("info msg", zap.String("hello", "world"))
+
+	if !ft.failed {
+		t.Fatal("RequireEntry must fail when fields mismatch")
+	}
+}
+
+type fakeT struct {
+	name   string
+	failed bool
+}
+
+func (t *fakeT) Name() string                              { return t.name }
+func (t *fakeT) Logf(format string, args ...interface{})   { _ = fmt.Sprintf(format, args...) }
+func (t *fakeT) Fatalf(format string, args ...interface{}) { t.failed = true }
+func (t *fakeT) Helper()                                   {}
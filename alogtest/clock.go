@@ -0,0 +1,95 @@
+package alogtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/csh0101/alog/writers"
+)
+
+var _ writers.Clock = (*Clock)(nil)
+
+// Clock is a fake writers.Clock which only moves forward by Advance.
+// Like time.Ticker, its tickers drop ticks for slow receivers.
+type Clock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	tickers []*ticker
+}
+
+// NewClock new a fake clock starting at the given time.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *Clock) NewTicker(d time.Duration) writers.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t := &ticker{
+		c:      make(chan time.Time, 1),
+		period: d,
+		next:   c.now.Add(d),
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward and fires the tickers which are due.
+func (c *Clock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+
+	active := c.tickers[:0]
+	for _, t := range c.tickers {
+		if t.isStopped() {
+			continue
+		}
+		for !t.next.After(c.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+		active = append(active, t)
+	}
+	c.tickers = active
+}
+
+type ticker struct {
+	c      chan time.Time
+	period time.Duration
+	next   time.Time
+
+	mutex   sync.Mutex
+	stopped bool
+}
+
+func (t *ticker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *ticker) Stop() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.stopped = true
+}
+
+func (t *ticker) isStopped() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.stopped
+}
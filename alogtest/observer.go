@@ -0,0 +1,208 @@
+package alogtest
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Entry is a log entry recorded by the test logger.
+type Entry struct {
+	Time       time.Time
+	Level      zapcore.Level
+	LoggerName string
+	Message    string
+	Caller     zapcore.EntryCaller
+	Fields     []zapcore.Field
+}
+
+// ContextMap returns the fields of the entry as a map, which is convenient for comparing values.
+func (e Entry) ContextMap() map[string]interface{} {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, f := range e.Fields {
+		f.AddTo(encoder)
+	}
+	return encoder.Fields
+}
+
+// HasField reports whether the entry carries a field equal to f.
+func (e Entry) HasField(f zapcore.Field) bool {
+	for _, ef := range e.Fields {
+		if ef.Equals(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// ObservedLogs is a concurrency-safe collection of recorded entries.
+type ObservedLogs struct {
+	t       TestingT
+	mutex   sync.RWMutex
+	entries []Entry
+}
+
+// Len returns the number of recorded entries.
+func (o *ObservedLogs) Len() int {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	return len(o.entries)
+}
+
+// All returns a copy of all recorded entries.
+func (o *ObservedLogs) All() []Entry {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	entries := make([]Entry, len(o.entries))
+	copy(entries, o.entries)
+	return entries
+}
+
+// TakeAll returns all recorded entries and clears the collection.
+func (o *ObservedLogs) TakeAll() []Entry {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	entries := o.entries
+	o.entries = nil
+	return entries
+}
+
+// Filter returns a copy of the collection holding only the entries that keep returns true for.
+func (o *ObservedLogs) Filter(keep func(Entry) bool) *ObservedLogs {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	filtered := &ObservedLogs{t: o.t}
+	for _, e := range o.entries {
+		if keep(e) {
+			filtered.entries = append(filtered.entries, e)
+		}
+	}
+	return filtered
+}
+
+// FilterLevel filters entries to those logged at exactly the given level.
+func (o *ObservedLogs) FilterLevel(level zapcore.Level) *ObservedLogs {
+	return o.Filter(func(e Entry) bool {
+		return e.Level == level
+	})
+}
+
+// FilterMessage filters entries to those with the given message.
+func (o *ObservedLogs) FilterMessage(msg string) *ObservedLogs {
+	return o.Filter(func(e Entry) bool {
+		return e.Message == msg
+	})
+}
+
+// FilterMessageSnippet filters entries to those whose message contains snippet.
+func (o *ObservedLogs) FilterMessageSnippet(snippet string) *ObservedLogs {
+	return o.Filter(func(e Entry) bool {
+		return strings.Contains(e.Message, snippet)
+	})
+}
+
+// FilterLoggerName filters entries to those logged by the logger with the given name.
+func (o *ObservedLogs) FilterLoggerName(name string) *ObservedLogs {
+	return o.Filter(func(e Entry) bool {
+		return e.LoggerName == name
+	})
+}
+
+// FilterField filters entries to those carrying a field equal to f.
+func (o *ObservedLogs) FilterField(f zapcore.Field) *ObservedLogs {
+	return o.Filter(func(e Entry) bool {
+		return e.HasField(f)
+	})
+}
+
+// FilterFieldKey filters entries to those carrying a field with the given key.
+func (o *ObservedLogs) FilterFieldKey(key string) *ObservedLogs {
+	return o.Filter(func(e Entry) bool {
+		for _, f := range e.Fields {
+			if f.Key == key {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// RequireEntry fails the test immediately unless an entry with the given message
+// and all given fields was recorded. It returns the first matched entry.
+func (o *ObservedLogs) RequireEntry(msg string, fields ...zapcore.Field) Entry {
+	o.t.Helper()
+
+	matched := o.FilterMessage(msg)
+	for _, f := range fields {
+		matched = matched.FilterField(f)
+	}
+	if matched.Len() == 0 {
+		o.t.Fatalf("no log entry matched message %q with %d field(s), recorded %d entries", msg, len(fields), o.Len())
+		return Entry{}
+	}
+	return matched.All()[0]
+}
+
+// RequireNoEntry fails the test immediately if an entry with the given message was recorded.
+func (o *ObservedLogs) RequireNoEntry(msg string) {
+	o.t.Helper()
+
+	if n := o.FilterMessage(msg).Len(); n > 0 {
+		o.t.Fatalf("unexpected log entry with message %q, recorded %d time(s)", msg, n)
+	}
+}
+
+func (o *ObservedLogs) add(e Entry) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.entries = append(o.entries, e)
+}
+
+var _ zapcore.Core = (*observerCore)(nil)
+
+// observerCore records every entry accepted by the core it observes.
+type observerCore struct {
+	zapcore.LevelEnabler
+	logs    *ObservedLogs
+	context []zapcore.Field
+}
+
+func (c *observerCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *observerCore) With(fields []zapcore.Field) zapcore.Core {
+	context := make([]zapcore.Field, 0, len(c.context)+len(fields))
+	context = append(context, c.context...)
+	context = append(context, fields...)
+	return &observerCore{
+		LevelEnabler: c.LevelEnabler,
+		logs:         c.logs,
+		context:      context,
+	}
+}
+
+func (c *observerCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	all := make([]zapcore.Field, 0, len(c.context)+len(fields))
+	all = append(all, c.context...)
+	all = append(all, fields...)
+	c.logs.add(Entry{
+		Time:       ent.Time,
+		Level:      ent.Level,
+		LoggerName: ent.LoggerName,
+		Message:    ent.Message,
+		Caller:     ent.Caller,
+		Fields:     all,
+	})
+	return nil
+}
+
+func (c *observerCore) Sync() error {
+	return nil
+}
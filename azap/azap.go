@@ -2,14 +2,17 @@ package azap
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"strings"
-	"sync/atomic"
+	"sync"
+	"time"
 
 	"github.com/csh0101/alog/options"
 	"github.com/csh0101/alog/types"
 
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -18,7 +21,24 @@ import (
 // It provides an abstract interface based on uber/zap.
 type ZapLogger interface {
 	types.Logger
+	types.LogLevelsHotReloader
+	types.LogVModuleHotReloader
+	types.LogLevelInspector
+	types.LogSettingsInspector
+	types.LogStatsInspector
+	types.LogSugarFunc
+	types.LogWriterFunc
+	types.LogRecoverFunc
+
 	types.LogOptionFuncs
+	types.LogOwnedWriterOptionFunc
+	types.LogCoreOptionFuncs
+	types.LogEnvOptionFunc
+	types.LogRulesOptionFuncs
+	types.LogHookOptionFunc
+	types.LogDropOptionFuncs
+	types.LogErrorOptionFuncs
+	types.LogFatalActionOptionFunc
 }
 
 type zapLogger struct {
@@ -28,7 +48,32 @@ type zapLogger struct {
 	encoding      string
 	disableCaller bool
 	callerSkip    int
-	verboseFilter int32
+	verbosity     *verboseState
+	vmodule       string
+	wrapCores     []func(zapcore.Core) zapcore.Core
+	core          zapcore.Core
+	levelRules    string
+	levels        *levelState
+	settings      *logSettings
+	hooks         []hookConfig
+	dedup         *dedupConfig
+	sampling      map[zapcore.Level]samplingConfig
+	rateLimits    map[zapcore.Level]rateLimitConfig
+	stats         *logStats
+	stacktrace    zapcore.Level
+	richErrors    bool
+	fatalAction   types.FatalAction
+	errorOutput   zapcore.WriteSyncer
+	// optionErr collects the invalid options, which fail NewLogger
+	optionErr error
+	// fatal is the fatal hook of the root logger, shared by its clones
+	fatal *fatalHook
+	// owned are closed by Close of the root logger in order, e.g. the writers opened by the logger itself
+	owned []func() error
+	// flush are called by Close of the root logger before syncing, e.g. to write the pending dedup summaries
+	flush     []func() error
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // NewLogger new a zap logger instance.
@@ -46,11 +91,35 @@ func NewLogger(loggerName string, opts ...options.LoggerOption) (ZapLogger, erro
 		encoding:      "json",
 		disableCaller: false,
 		callerSkip:    0,
-		verboseFilter: 0,
+		verbosity:     newVerboseState(0),
+		settings:      newLogSettings(),
+		stats:         &logStats{},
+		stacktrace:    zapcore.FatalLevel,
+		errorOutput:   zapcore.Lock(os.Stderr),
 	}
 	for _, opt := range opts {
 		opt(logger)
 	}
+	if logger.optionErr != nil {
+		logger.closeOwned()
+		return nil, fmt.Errorf("bad options, %w", logger.optionErr)
+	}
+	// the environment overrides the options
+	if err := logger.applyEnv(); err != nil {
+		logger.closeOwned()
+		return nil, err
+	}
+
+	if err := logger.verbosity.reload(logger.vmodule); err != nil {
+		logger.closeOwned()
+		return nil, err
+	}
+
+	logger.levels = newLevelState(logger.logLevel)
+	if err := logger.levels.reload(logger.levelRules); err != nil {
+		logger.closeOwned()
+		return nil, err
+	}
 
 	var encoder zapcore.Encoder
 	var newCore zapcore.Core
@@ -59,9 +128,7 @@ func NewLogger(loggerName string, opts ...options.LoggerOption) (ZapLogger, erro
 
 	// encoder
 	{
-		encoderConfig := zap.NewProductionEncoderConfig()
-		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoderConfig := NewEncoderConfig()
 
 		if logger.encoding == "console" {
 			encoder = zapcore.NewConsoleEncoder(encoderConfig)
@@ -76,18 +143,55 @@ func NewLogger(loggerName string, opts ...options.LoggerOption) (ZapLogger, erro
 				writeSyners = append(writeSyners, zapcore.AddSync(writer))
 			}
 		}
-		newCore = zapcore.NewCore(
-			encoder,
-			zap.CombineWriteSyncers(writeSyners...),
-			logger.logLevel,
-		)
+		// levels are decided by the outermost levelCore, which knows the logger name
+		if logger.core != nil {
+			newCore = logger.core
+		} else {
+			newCore = zapcore.NewCore(
+				encoder,
+				zap.CombineWriteSyncers(writeSyners...),
+				zapcore.DebugLevel,
+			)
+		}
+		if logger.richErrors {
+			newCore = &errorCore{Core: newCore}
+		}
+		if logger.dedup != nil {
+			dedup := newDedupCore(newCore, *logger.dedup, logger.errorOutput)
+			logger.flush = append(logger.flush, dedup.state.Close)
+			newCore = dedup
+		}
+		if len(logger.sampling) > 0 {
+			newCore = newSamplingCore(newCore, logger.sampling, logger.stats)
+		}
+		if len(logger.rateLimits) > 0 {
+			newCore = newRateLimitCore(newCore, logger.rateLimits, logger.stats)
+		}
+		for _, wrap := range logger.wrapCores {
+			newCore = wrap(newCore)
+		}
+		// hooks are not affected by the wrapped cores, e.g. sampling
+		if len(logger.hooks) > 0 {
+			cores := []zapcore.Core{newCore}
+			for _, c := range logger.hooks {
+				hook := newHookCore(c)
+				if hook.async != nil {
+					logger.owned = append(logger.owned, hook.async.Close)
+				}
+				cores = append(cores, hook)
+			}
+			newCore = zapcore.NewTee(cores...)
+		}
+		newCore = &levelCore{Core: newCore, state: logger.levels}
 	}
 	// options
 	{
+		logger.fatal = &fatalHook{logger: logger, action: logger.fatalAction}
 		options = []zap.Option{
-			zap.ErrorOutput(zapcore.AddSync(os.Stderr)),
-			zap.AddStacktrace(zapcore.FatalLevel),
+			zap.ErrorOutput(logger.errorOutput),
+			zap.AddStacktrace(logger.stacktrace),
 			zap.AddCallerSkip(logger.callerSkip),
+			zap.WithFatalHook(logger.fatal),
 		}
 		if !logger.disableCaller {
 			options = append(options, zap.AddCaller())
@@ -100,19 +204,62 @@ func NewLogger(loggerName string, opts ...options.LoggerOption) (ZapLogger, erro
 	return logger, nil
 }
 
+// Close flushes the pending entries and syncs the logger, then flushes, syncs and closes the owned writers in order.
+// Only the root logger closes the owned writers, it is safe to call Close more than once.
 func (l *zapLogger) Close() error {
-	if l.Logger != nil {
-		return l.Logger.Sync()
+	l.closeOnce.Do(func() {
+		for _, f := range l.flush {
+			l.closeErr = multierr.Append(l.closeErr, f())
+		}
+		if l.Logger != nil {
+			l.closeErr = multierr.Append(l.closeErr, l.Logger.Sync())
+		}
+		l.closeErr = multierr.Append(l.closeErr, l.closeOwned())
+	})
+	return l.closeErr
+}
+
+func (l *zapLogger) closeOwned() error {
+	var err error
+	for _, c := range l.owned {
+		err = multierr.Append(err, c())
+	}
+	l.owned = nil
+	return err
+}
+
+// ownedWriter returns the function closing an owned writer.
+func ownedWriter(w io.Writer) func() error {
+	return func() error {
+		var err error
+		if f, ok := w.(interface{ Flush() error }); ok {
+			err = multierr.Append(err, f.Flush())
+		}
+		if s, ok := w.(interface{ Sync() error }); ok {
+			err = multierr.Append(err, s.Sync())
+		}
+		if c, ok := w.(io.Closer); ok {
+			err = multierr.Append(err, c.Close())
+		}
+		return err
 	}
-	return nil
 }
 
 func (l *zapLogger) Enabled(level zapcore.Level) bool {
-	return l.Core().Enabled(level)
+	return l.levels.enabled(l.Name(), level)
 }
 
 func (l *zapLogger) HotReloadLogLevel(level zapcore.Level) error {
 	l.logLevel.SetLevel(level)
+	l.settings.set(settingLevel, types.LogSettingFromHotReload)
+	return nil
+}
+
+func (l *zapLogger) HotReloadLogLevels(rules string) error {
+	if err := l.levels.reload(rules); err != nil {
+		return err
+	}
+	l.settings.set(settingLevels, types.LogSettingFromHotReload)
 	return nil
 }
 
@@ -120,12 +267,38 @@ func (l *zapLogger) HostReloadLogVerbose(verbose int) error {
 	if verbose < 0 {
 		verbose = 0
 	}
-	atomic.StoreInt32(&l.verboseFilter, int32(verbose))
+	l.verbosity.filter.Store(int32(verbose))
+	l.settings.set(settingVerbose, types.LogSettingFromHotReload)
 	return nil
 }
 
+func (l *zapLogger) HotReloadLogVModule(rules string) error {
+	if err := l.verbosity.reload(rules); err != nil {
+		return err
+	}
+	l.settings.set(settingVModule, types.LogSettingFromHotReload)
+	return nil
+}
+
+func (l *zapLogger) LogLevel() zapcore.Level {
+	return l.logLevel.Level()
+}
+
+func (l *zapLogger) LogLevels() string {
+	return l.levels.rules.Load().spec
+}
+
+func (l *zapLogger) LogVerbose() int {
+	return int(l.verbosity.filter.Load())
+}
+
+func (l *zapLogger) LogVModule() string {
+	return l.verbosity.vmodule.Load().spec
+}
+
 func (l *zapLogger) LogLevelOption(v zapcore.Level) {
 	l.logLevel.SetLevel(v)
+	l.settings.set(settingLevel, types.LogSettingFromOption)
 }
 
 func (l *zapLogger) LogWriterOption(w ...io.Writer) {
@@ -134,6 +307,16 @@ func (l *zapLogger) LogWriterOption(w ...io.Writer) {
 	} else {
 		l.writers = w
 	}
+	l.settings.set(settingWriters, types.LogSettingFromOption)
+}
+
+func (l *zapLogger) LogOwnedWriterOption(w ...io.Writer) {
+	l.LogWriterOption(w...)
+	for _, writer := range w {
+		if writer != nil {
+			l.owned = append(l.owned, ownedWriter(writer))
+		}
+	}
 }
 
 func (l *zapLogger) LogStructuredFormatOption(v bool) {
@@ -142,10 +325,12 @@ func (l *zapLogger) LogStructuredFormatOption(v bool) {
 	} else {
 		l.encoding = "console"
 	}
+	l.settings.set(settingFormat, types.LogSettingFromOption)
 }
 
 func (l *zapLogger) LogDisableCallerOption(v bool) {
 	l.disableCaller = v
+	l.settings.set(settingDisableCaller, types.LogSettingFromOption)
 }
 
 func (l *zapLogger) LogAddCallerSkipOption(v int) {
@@ -154,18 +339,130 @@ func (l *zapLogger) LogAddCallerSkipOption(v int) {
 
 func (l *zapLogger) LogVerboseFilterOption(v int) {
 	if v < 0 {
-		l.verboseFilter = 0
+		v = 0
+	}
+	l.verbosity.filter.Store(int32(v))
+	l.settings.set(settingVerbose, types.LogSettingFromOption)
+}
+
+func (l *zapLogger) LogVModuleOption(v string) {
+	l.vmodule = v
+	l.settings.set(settingVModule, types.LogSettingFromOption)
+}
+
+func (l *zapLogger) LogWrapCoreOption(f func(zapcore.Core) zapcore.Core) {
+	if f != nil {
+		l.wrapCores = append(l.wrapCores, f)
+	}
+}
+
+func (l *zapLogger) LogHookOption(minLevel zapcore.Level, hook types.LogHook, queueSize int) {
+	if hook != nil {
+		l.hooks = append(l.hooks, hookConfig{minLevel: minLevel, hook: hook, queueSize: queueSize})
+	}
+}
+
+func (l *zapLogger) LogDedupOption(window time.Duration, maxKeys int, fields ...string) {
+	if window > 0 {
+		l.dedup = &dedupConfig{window: window, maxKeys: maxKeys, fields: fields}
 	} else {
-		l.verboseFilter = int32(v)
+		l.dedup = nil
 	}
 }
 
+func (l *zapLogger) LogSamplingOption(level zapcore.Level, tick time.Duration, first, thereafter int) {
+	switch {
+	case level > zapcore.WarnLevel:
+		// errors are never sampled away
+		l.optionErr = multierr.Append(l.optionErr, fmt.Errorf("sampling of level %s is not allowed, only levels up to WARN can be sampled", level))
+		return
+	case tick < 0 || first < 0 || thereafter < 0:
+		l.optionErr = multierr.Append(l.optionErr, fmt.Errorf("bad sampling of level %s, tick %v, first %d and thereafter %d must not be negative", level, tick, first, thereafter))
+		return
+	}
+	if tick == 0 {
+		tick = time.Second
+	}
+	if first == 0 {
+		first = 1
+	}
+	if l.sampling == nil {
+		l.sampling = make(map[zapcore.Level]samplingConfig)
+	}
+	l.sampling[level] = samplingConfig{tick: tick, first: first, thereafter: thereafter}
+}
+
+func (l *zapLogger) LogRateLimitOption(level zapcore.Level, key types.RateLimitKey, perSecond float64, burst int) {
+	switch {
+	case level > zapcore.WarnLevel:
+		l.optionErr = multierr.Append(l.optionErr, fmt.Errorf("rate limit of level %s is not allowed, only levels up to WARN can be limited", level))
+		return
+	case perSecond <= 0 || burst < 0:
+		l.optionErr = multierr.Append(l.optionErr, fmt.Errorf("bad rate limit of level %s, perSecond %v must be positive and burst %d must not be negative", level, perSecond, burst))
+		return
+	}
+	if burst == 0 {
+		burst = 1
+	}
+	if l.rateLimits == nil {
+		l.rateLimits = make(map[zapcore.Level]rateLimitConfig)
+	}
+	l.rateLimits[level] = rateLimitConfig{key: key, perSecond: perSecond, burst: burst}
+}
+
+func (l *zapLogger) LogStats() types.LogStats {
+	return l.stats.snapshot()
+}
+
+func (l *zapLogger) LogStacktraceLevelOption(v zapcore.Level) {
+	l.stacktrace = v
+}
+
+func (l *zapLogger) LogRichErrorsOption(v bool) {
+	l.richErrors = v
+}
+
+func (l *zapLogger) LogFatalActionOption(action types.FatalAction) {
+	l.fatalAction = action
+}
+
+func (l *zapLogger) LogCoreOption(core zapcore.Core) {
+	l.core = core
+	l.settings.set(settingWriters, types.LogSettingFromOption)
+}
+
+func (l *zapLogger) LogLevelRulesOption(v string) {
+	l.levelRules = v
+	l.settings.set(settingLevels, types.LogSettingFromOption)
+}
+
 func (l *zapLogger) Named(v string) types.Logger {
 	newLogger := l.clone()
 	newLogger.Logger = l.Logger.Named(v)
 	return newLogger
 }
 
+func (l *zapLogger) With(fields ...zapcore.Field) types.Logger {
+	newLogger := l.clone()
+	newLogger.Logger = l.Logger.With(fields...)
+	return newLogger
+}
+
+func (l *zapLogger) Writer(level zapcore.Level) io.WriteCloser {
+	return NewLineWriter(l, level)
+}
+
+func (l *zapLogger) Sugar() types.SugaredLogger {
+	return newSugaredLogger(l, l.withCallerSkip(sugarCallerSkip))
+}
+
+func (l *zapLogger) withCallerSkip(n int) ZapLogger {
+	newLogger := l.clone()
+	newLogger.callerSkip += n
+	newLogger.Logger = l.Logger.WithOptions(zap.AddCallerSkip(n))
+	return newLogger
+}
+
 func (l *zapLogger) clone() *zapLogger {
 	return &zapLogger{
 		Logger:        l.Logger,
@@ -174,7 +471,15 @@ func (l *zapLogger) clone() *zapLogger {
 		encoding:      l.encoding,
 		disableCaller: l.disableCaller,
 		callerSkip:    l.callerSkip,
-		verboseFilter: atomic.LoadInt32(&l.verboseFilter),
+		verbosity:     l.verbosity,
+		vmodule:       l.vmodule,
+		wrapCores:     l.wrapCores,
+		core:          l.core,
+		levelRules:    l.levelRules,
+		levels:        l.levels,
+		settings:      l.settings,
+		stats:         l.stats,
+		fatal:         l.fatal,
 	}
 }
 
@@ -203,6 +508,53 @@ func newVerboseZapLogger(verbose int, logger *zapLogger) *verboseZapLogger {
 	}
 }
 
+// Enabled reports whether the level is enabled and logs of the verbose are printed.
+func (l *verboseZapLogger) Enabled(level zapcore.Level) bool {
+	return l.verbosity.enabled(l.verbose, 1+l.callerSkip) && l.zapLogger.Enabled(level)
+}
+
+// V replaces the verbose of the logger.
+func (l *verboseZapLogger) V(verbose int) types.Logger {
+	if verbose < 0 {
+		verbose = 0
+	}
+	return &verboseZapLogger{
+		verbose:   int32(verbose),
+		zapLogger: l.zapLogger,
+	}
+}
+
+func (l *verboseZapLogger) Named(v string) types.Logger {
+	newLogger := l.zapLogger.clone()
+	newLogger.Logger = l.Logger.Named(v)
+	return &verboseZapLogger{
+		verbose:   l.verbose,
+		zapLogger: newLogger,
+	}
+}
+
+func (l *verboseZapLogger) With(fields ...zapcore.Field) types.Logger {
+	return &verboseZapLogger{
+		verbose:   l.verbose,
+		zapLogger: l.zapLogger.With(fields...).(*zapLogger),
+	}
+}
+
+func (l *verboseZapLogger) Writer(level zapcore.Level) io.WriteCloser {
+	return NewLineWriter(l, level)
+}
+
+func (l *verboseZapLogger) Sugar() types.SugaredLogger {
+	return newSugaredLogger(l, l.withCallerSkip(sugarCallerSkip))
+}
+
+func (l *verboseZapLogger) withCallerSkip(n int) ZapLogger {
+	return &verboseZapLogger{
+		verbose:   l.verbose,
+		zapLogger: l.zapLogger.withCallerSkip(n).(*zapLogger),
+	}
+}
+
 func (l *verboseZapLogger) Debug(msg string, fields ...zapcore.Field) {
 	if l.verboseOK() {
 		l.zapLogger.Debug(msg, fields...)
@@ -238,6 +590,7 @@ func (l *verboseZapLogger) Fatal(msg string, fields ...zapcore.Field) {
 	}
 }
 
+// verboseOK must be called by the logging methods directly, the vmodule rules are matched against their caller.
 func (l *verboseZapLogger) verboseOK() bool {
-	return l.verbose <= atomic.LoadInt32(&l.zapLogger.verboseFilter)
+	return l.verbosity.enabled(l.verbose, 2+l.callerSkip)
 }
@@ -0,0 +1,67 @@
+package azap_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+
+	"go.uber.org/multierr"
+)
+
+type ownedWriter struct {
+	bytes.Buffer
+	name  string
+	calls *[]string
+	err   error
+}
+
+func (w *ownedWriter) Flush() error {
+	*w.calls = append(*w.calls, w.name+".flush")
+	return nil
+}
+
+func (w *ownedWriter) Sync() error {
+	*w.calls = append(*w.calls, w.name+".sync")
+	return nil
+}
+
+func (w *ownedWriter) Close() error {
+	*w.calls = append(*w.calls, w.name+".close")
+	return w.err
+}
+
+func TestZapLogger_CloseOwned(t *testing.T) {
+	var calls []string
+	a := &ownedWriter{name: "a", calls: &calls, err: errors.New("a failed")}
+	b := &ownedWriter{name: "b", calls: &calls, err: errors.New("b failed")}
+	logger, err := azap.NewLogger(t.Name(), options.WithOwnedWriter(a, b))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	logger.Info("hello")
+
+	// a child only syncs
+	if err := logger.Named("child").Close(); err != nil {
+		t.Fatalf("close child failed, %v", err)
+	}
+	calls = calls[:0]
+
+	err = logger.Close()
+	if errs := multierr.Errors(err); len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", err)
+	}
+	expected := "a.sync,b.sync,a.flush,a.sync,a.close,b.flush,b.sync,b.close"
+	if got := strings.Join(calls, ","); got != expected {
+		t.Fatalf("expected calls %s, got %s", expected, got)
+	}
+	if err2 := logger.Close(); err2 != err || len(calls) != 8 {
+		t.Fatalf("close must be idempotent, got %v, calls %v", err2, calls)
+	}
+	if !strings.Contains(a.String(), "hello") || !strings.Contains(b.String(), "hello") {
+		t.Fatalf("entry is not written, %s %s", a.String(), b.String())
+	}
+}
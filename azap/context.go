@@ -2,6 +2,7 @@ package azap
 
 import (
 	"context"
+	"io"
 
 	"github.com/csh0101/alog/types"
 	"go.uber.org/zap"
@@ -14,13 +15,35 @@ type LoggerWithCtx struct {
 	ZapLogger
 }
 
+// callerSkipper is implemented by the loggers of azap, so that LoggerWithCtx reports the right caller.
+type callerSkipper interface {
+	withCallerSkip(n int) ZapLogger
+}
+
+// Ctx returns a logger adding the fields of ctx to all of its logs.
+// Loggers derived from it by With, Named and V keep the context.
 func Ctx(ctx context.Context, l ZapLogger) ZapLogger {
+	if c, ok := l.(LoggerWithCtx); ok {
+		return LoggerWithCtx{ctx: ctx, ZapLogger: c.ZapLogger}
+	}
+	if skipper, ok := l.(callerSkipper); ok {
+		l = skipper.withCallerSkip(1)
+	}
 	return LoggerWithCtx{
 		ctx:       ctx,
 		ZapLogger: l,
 	}
 }
 
+// AddCallerSkip clones the logger of azap with more caller skip, for the wrappers of the logger.
+// The other loggers are returned as is.
+func AddCallerSkip(l types.Logger, skip int) types.Logger {
+	if skipper, ok := l.(callerSkipper); ok && skip > 0 {
+		return skipper.withCallerSkip(skip)
+	}
+	return l
+}
+
 // Context returns logger's context.
 func (l LoggerWithCtx) Context() context.Context {
 	return l.ctx
@@ -31,13 +54,53 @@ func (l LoggerWithCtx) Logger() types.Logger {
 	return l.ZapLogger
 }
 
+// With clones the logger with the context and adds the fields to all of its logs.
+func (l LoggerWithCtx) With(fields ...zapcore.Field) types.Logger {
+	return l.derive(l.ZapLogger.With(fields...))
+}
+
+// Named clones the logger with the context and rename it.
+func (l LoggerWithCtx) Named(n string) types.Logger {
+	return l.derive(l.ZapLogger.Named(n))
+}
+
+// V returns a verbose logger with the context.
+func (l LoggerWithCtx) V(verbose int) types.Logger {
+	return l.derive(l.ZapLogger.V(verbose))
+}
+
+// Enabled is wrapped for the same depth of the caller as the logging methods.
+func (l LoggerWithCtx) Enabled(level zapcore.Level) bool {
+	return l.ZapLogger.Enabled(level)
+}
+
+func (l LoggerWithCtx) withCallerSkip(n int) ZapLogger {
+	if skipper, ok := l.ZapLogger.(callerSkipper); ok {
+		return LoggerWithCtx{ctx: l.ctx, ZapLogger: skipper.withCallerSkip(n)}
+	}
+	return l
+}
+
+func (l LoggerWithCtx) derive(logger types.Logger) types.Logger {
+	if zl, ok := logger.(ZapLogger); ok {
+		return LoggerWithCtx{ctx: l.ctx, ZapLogger: zl}
+	}
+	return logger
+}
+
+// Writer returns a writer logging the lines with the context.
+func (l LoggerWithCtx) Writer(level zapcore.Level) io.WriteCloser {
+	return NewLineWriter(l, level)
+}
+
 // Sugar returns a sugared logger with the context.
-// func (l LoggerWithCtx) Sugar() SugaredLoggerWithCtx {
-// 	return SugaredLoggerWithCtx{
-// 		ctx: l.ctx,
-// 		s:   l.l.Sugar(),
-// 	}
-// }
+func (l LoggerWithCtx) Sugar() types.SugaredLogger {
+	logger := l
+	if skipper, ok := l.ZapLogger.(callerSkipper); ok {
+		logger.ZapLogger = skipper.withCallerSkip(sugarCallerSkip)
+	}
+	return newSugaredLogger(l, logger)
+}
 
 // WithOptions clones the current Logger, applies the supplied Options,
 // and returns the resulting Logger. It's safe to use concurrently.
@@ -57,6 +120,10 @@ func (l LoggerWithCtx) Logger() types.Logger {
 // }
 
 func (l LoggerWithCtx) buildFields(ctx context.Context) []zapcore.Field {
+	return ctxFields(ctx)
+}
+
+func ctxFields(ctx context.Context) []zapcore.Field {
 	fields := make([]zapcore.Field, 0, 1)
 	if requestId, ok := ctx.Value("request_id").(string); ok {
 		fields = append(fields, zap.String("request_id", requestId))
@@ -107,8 +174,10 @@ func (l LoggerWithCtx) Panic(msg string, fields ...zapcore.Field) {
 // Fatal logs a message at FatalLevel. The message includes any fields passed
 // at the log site, as well as any fields accumulated on the logger.
 //
-// The logger then calls os.Exit(1), even if logging at FatalLevel is
-// disabled.
+// The logger is then closed and the fatal action runs, even if logging at
+// FatalLevel is disabled. The action is os.Exit(1) by default, see
+// options.WithFatalAction, and Fatal panics if the action returns, e.g. with
+// options.WithFatalPanic.
 func (l LoggerWithCtx) Fatal(msg string, fields ...zapcore.Field) {
 	fields = append(fields, l.buildFields(l.ctx)...)
 	l.ZapLogger.Fatal(msg, fields...)
@@ -1,8 +1,10 @@
 package azap_test
 
 import (
+	"bytes"
 	"context"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/csh0101/alog/azap"
@@ -24,3 +26,38 @@ func TestAzapDebug(t *testing.T) {
 	azap.Ctx(ctx, logger).Debug("this is debug msg", zap.String("hello", "debug"))
 	azap.Ctx(ctx, logger).Info("this is info msg", zap.String("hello", "info"))
 }
+
+func TestLoggerWithCtx_Derived(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "request_id", "123456")
+	buf := bytes.NewBuffer(nil)
+	logger, err := azap.NewLogger(t.Name(), options.WithWriter(buf))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	derived := azap.Ctx(ctx, logger).With(zap.String("k", "v")).Named("child").V(2)
+	derived.Info("v2-before")
+	if err := logger.HostReloadLogVerbose(2); err != nil {
+		t.Fatalf("reload verbose failed, %v", err)
+	}
+	derived.Info("v2-after")
+	derived.With(zap.Int("n", 1)).Info("with-n")
+
+	if strings.Contains(buf.String(), "v2-before") {
+		t.Fatalf("verbose is lost by derived loggers, got %s", buf.String())
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %s", buf.String())
+	}
+	for _, line := range lines {
+		for _, s := range []string{`"request_id":"123456"`, `"k":"v"`, `.child"`, "azap/context_test.go"} {
+			if !strings.Contains(line, s) {
+				t.Fatalf("`%s` is missing, got %s", s, line)
+			}
+		}
+	}
+	if !strings.Contains(lines[1], `"n":1`) {
+		t.Fatalf("fields of With are missing, got %s", lines[1])
+	}
+}
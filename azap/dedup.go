@@ -0,0 +1,361 @@
+package azap
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// defaultDedupMaxKeys bounds the entries tracked by a dedupCore if the limit is not set.
+const defaultDedupMaxKeys = 1024
+
+type dedupConfig struct {
+	window  time.Duration
+	maxKeys int
+	fields  []string
+}
+
+// dedupCore collapses the repeats of an entry with the same level, logger name, message and selected fields
+// within the window, a summary entry is written for the repeats after the window.
+// Entries above ERROR are never collapsed.
+type dedupCore struct {
+	zapcore.Core
+	state *dedupState
+	// keys are the selected fields added by With, as parts of the dedup key
+	keys []string
+}
+
+// dedupState is shared by a dedupCore and all of its clones.
+type dedupState struct {
+	window  time.Duration
+	maxKeys int
+	fields  map[string]bool
+
+	// errorOutput reports the errors of the summaries flushed in background
+	errorOutput zapcore.WriteSyncer
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // *dedupEntry, oldest first
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type dedupEntry struct {
+	key string
+	// core is the one that wrote the first entry, with the fields added by With
+	core     zapcore.Core
+	ent      zapcore.Entry
+	fields   []zapcore.Field
+	repeated int
+	lastSeen time.Time
+}
+
+func newDedupCore(core zapcore.Core, c dedupConfig, errorOutput zapcore.WriteSyncer) *dedupCore {
+	state := &dedupState{
+		window:      c.window,
+		maxKeys:     c.maxKeys,
+		fields:      make(map[string]bool, len(c.fields)),
+		errorOutput: errorOutput,
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if state.maxKeys <= 0 {
+		state.maxKeys = defaultDedupMaxKeys
+	}
+	for _, f := range c.fields {
+		state.fields[f] = true
+	}
+	go state.run()
+	return &dedupCore{Core: core, state: state}
+}
+
+func (c *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dedupCore{
+		Core:  c.Core.With(fields),
+		state: c.state,
+		keys:  append(c.keys[:len(c.keys):len(c.keys)], c.state.keysOf(fields)...),
+	}
+}
+
+func (c *dedupCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *dedupCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level > zapcore.ErrorLevel {
+		return writeChecked(c.Core, ent, fields)
+	}
+
+	var key strings.Builder
+	fmt.Fprintf(&key, "%d\x00%s\x00%s", ent.Level, ent.LoggerName, ent.Message)
+	for _, keys := range [][]string{c.keys, c.state.keysOf(fields)} {
+		for _, k := range keys {
+			key.WriteString("\x00")
+			key.WriteString(k)
+		}
+	}
+
+	expired, first, repeated := c.state.observe(key.String(), c.Core, ent, fields)
+	if repeated != nil {
+		c.state.snapshot(key.String(), repeated)
+	}
+	err := flushDedupEntries(expired)
+	if first {
+		err = multierr.Append(err, writeChecked(c.Core, ent, fields))
+	}
+	return err
+}
+
+// writeChecked checks the entry again by the core, which may be a tee of cores with different levels.
+// The write errors of the cores are returned, so they are reported to the ErrorOutput of the logger.
+func writeChecked(core zapcore.Core, ent zapcore.Entry, fields []zapcore.Field) error {
+	ce := core.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+	var errs writeErrors
+	ce.ErrorOutput = &errs
+	ce.Write(fields...)
+	return errs.err
+}
+
+// writeErrors collects the write errors reported by a CheckedEntry as `<time> write error: <error>`.
+type writeErrors struct {
+	err error
+}
+
+func (w *writeErrors) Write(b []byte) (int, error) {
+	msg := strings.TrimSpace(string(b))
+	if _, after, ok := strings.Cut(msg, " write error: "); ok {
+		msg = after
+	}
+	w.err = multierr.Append(w.err, errors.New(msg))
+	return len(b), nil
+}
+
+func (w *writeErrors) Sync() error {
+	return nil
+}
+
+func (s *dedupState) keysOf(fields []zapcore.Field) []string {
+	var keys []string
+	for _, f := range fields {
+		if !s.fields[f.Key] {
+			continue
+		}
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		keys = append(keys, fmt.Sprintf("%s=%v", f.Key, enc.Fields[f.Key]))
+	}
+	return keys
+}
+
+// observe records the entry and reports whether it is the first one of its key in the window.
+// The expired and evicted entries are returned to be flushed, and the entry repeated for the
+// first time is returned to snapshot its fields.
+func (s *dedupState) observe(key string, core zapcore.Core, ent zapcore.Entry, fields []zapcore.Field) (expired []*dedupEntry, first bool, repeated *dedupEntry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil, true, nil
+	}
+	if el, ok := s.entries[key]; ok {
+		e := el.Value.(*dedupEntry)
+		if ent.Time.Sub(e.ent.Time) < s.window {
+			e.repeated++
+			e.lastSeen = ent.Time
+			if e.repeated == 1 {
+				repeated = e
+			}
+			return nil, false, repeated
+		}
+		expired = append(expired, s.remove(el))
+	}
+	if s.order.Len() >= s.maxKeys {
+		expired = append(expired, s.remove(s.order.Front()))
+	}
+	s.entries[key] = s.order.PushBack(&dedupEntry{
+		key:  key,
+		core: core,
+		ent:  ent,
+		// the fields belong to the caller
+		fields:   append([]zapcore.Field(nil), fields...),
+		lastSeen: ent.Time,
+	})
+	return expired, true, nil
+}
+
+// snapshot encodes the fields of a repeated entry which may change before the summary is written.
+// It is done without the lock, as encoding the fields may log.
+func (s *dedupState) snapshot(key string, e *dedupEntry) {
+	fields := snapshotFields(e.fields)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// the entry is flushed already
+	if el, ok := s.entries[key]; !ok || el.Value != e {
+		return
+	}
+	e.fields = fields
+}
+
+// snapshotFields encodes the fields whose value may change after the call, i.e. fields
+// like zap.Stringer, zap.Any and zap.Object, the others are kept as they are.
+func snapshotFields(fields []zapcore.Field) []zapcore.Field {
+	var snapshot []zapcore.Field
+	for i, f := range fields {
+		var frozen zapcore.Field
+		switch f.Type {
+		case zapcore.StringerType:
+			enc := zapcore.NewMapObjectEncoder()
+			f.AddTo(enc)
+			frozen = zap.Any(f.Key, enc.Fields[f.Key])
+		case zapcore.ReflectType:
+			b, err := json.Marshal(f.Interface)
+			if err != nil {
+				frozen = zap.String(f.Key+"Error", err.Error())
+				break
+			}
+			frozen = zap.Reflect(f.Key, json.RawMessage(b))
+		case zapcore.ObjectMarshalerType:
+			enc := zapcore.NewMapObjectEncoder()
+			if err := f.Interface.(zapcore.ObjectMarshaler).MarshalLogObject(enc); err != nil {
+				frozen = zap.String(f.Key+"Error", err.Error())
+				break
+			}
+			frozen = zap.Object(f.Key, frozenObject(enc.Fields))
+			if f.Key == "" {
+				frozen = zap.Inline(frozenObject(enc.Fields))
+			}
+		default:
+			continue
+		}
+		if snapshot == nil {
+			snapshot = append([]zapcore.Field(nil), fields...)
+		}
+		snapshot[i] = frozen
+	}
+	if snapshot == nil {
+		return fields
+	}
+	return snapshot
+}
+
+// frozenObject is an object encoded by a MapObjectEncoder, its fields are added in order of keys.
+type frozenObject map[string]interface{}
+
+func (o frozenObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if v, ok := o[k].(map[string]interface{}); ok {
+			if err := enc.AddObject(k, frozenObject(v)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := enc.AddReflected(k, o[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *dedupState) remove(el *list.Element) *dedupEntry {
+	e := s.order.Remove(el).(*dedupEntry)
+	delete(s.entries, e.key)
+	return e
+}
+
+// expire removes the entries whose window has passed, or all of them if now is zero.
+func (s *dedupState) expire(now time.Time) []*dedupEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var expired []*dedupEntry
+	for el := s.order.Front(); el != nil; el = s.order.Front() {
+		if e := el.Value.(*dedupEntry); !now.IsZero() && now.Sub(e.ent.Time) < s.window {
+			break
+		}
+		expired = append(expired, s.remove(el))
+	}
+	return expired
+}
+
+func (s *dedupState) run() {
+	defer close(s.done)
+
+	interval := s.window / 2
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			if err := flushDedupEntries(s.expire(now)); err != nil {
+				fmt.Fprintf(s.errorOutput, "%v flush dedup summaries failed, %v\n", time.Now().UTC(), err)
+				_ = s.errorOutput.Sync()
+			}
+		}
+	}
+}
+
+// Close stops collapsing entries and flushes the summaries of the pending ones.
+func (s *dedupState) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+	s.mutex.Unlock()
+
+	<-s.done
+	return flushDedupEntries(s.expire(time.Time{}))
+}
+
+// flushDedupEntries writes a summary for each entry with repeats.
+func flushDedupEntries(entries []*dedupEntry) error {
+	var err error
+	for _, e := range entries {
+		if e.repeated == 0 {
+			continue
+		}
+		ent := e.ent
+		ent.Time = time.Now()
+		ent.Stack = ""
+		fields := append(e.fields[:len(e.fields):len(e.fields)],
+			zap.Int("repeated", e.repeated),
+			zap.Time("first_seen", e.ent.Time),
+			zap.Time("last_seen", e.lastSeen),
+		)
+		err = multierr.Append(err, writeChecked(e.core, ent, fields))
+	}
+	return err
+}
@@ -0,0 +1,191 @@
+package azap_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type lockedBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) entries(t *testing.T) []map[string]interface{} {
+	t.Helper()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("bad entry %s, %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestZapLogger_Dedup(t *testing.T) {
+	buf := &lockedBuffer{}
+	logger, err := azap.NewLogger(t.Name(), options.WithWriter(buf), options.WithDedup(time.Hour, 2, "host"))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	db := logger.With(zap.String("host", "db"))
+	for i := 0; i < 5; i++ {
+		db.Warn("connection refused", zap.Int("attempt", i))
+		logger.Warn("connection refused", zap.String("host", "cache"))
+	}
+	for i := 0; i < 2; i++ {
+		func() {
+			defer func() { recover() }()
+			logger.Panic("never collapsed")
+		}()
+	}
+	if entries := buf.entries(t); len(entries) != 4 {
+		t.Fatalf("expected 4 entries before close, got %v", entries)
+	}
+
+	// evicts the oldest key, db
+	logger.Warn("another")
+	entries := buf.entries(t)
+	if len(entries) != 6 || entries[4]["host"] != "db" || entries[4]["repeated"] != float64(4) || entries[4]["attempt"] != float64(0) {
+		t.Fatalf("unexpected summary of the evicted key, %v", entries)
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("close failed, %v", err)
+	}
+	entries = buf.entries(t)
+	if len(entries) != 7 || entries[6]["host"] != "cache" || entries[6]["repeated"] != float64(4) {
+		t.Fatalf("unexpected summary flushed on close, %v", entries)
+	}
+	if entries[6]["first_seen"] == nil || entries[6]["last_seen"] == nil {
+		t.Fatalf("first_seen and last_seen are missing, %v", entries[6])
+	}
+}
+
+func TestZapLogger_DedupWindow(t *testing.T) {
+	buf := &lockedBuffer{}
+	logger, err := azap.NewLogger(t.Name(), options.WithWriter(buf), options.WithDedup(20*time.Millisecond, 0))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	defer logger.Close()
+
+	logger.Error("refused")
+	logger.Error("refused")
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if len(buf.entries(t)) == 2 {
+			break
+		}
+	}
+	if entries := buf.entries(t); len(entries) != 2 || entries[1]["repeated"] != float64(1) {
+		t.Fatalf("summary is not written after the window, %v", entries)
+	}
+}
+
+type mutableStringer struct{ s string }
+
+func (m *mutableStringer) String() string { return m.s }
+
+// closableBuffer drops the writes after Close.
+type closableBuffer struct {
+	lockedBuffer
+	closed bool
+}
+
+func (b *closableBuffer) Write(p []byte) (int, error) {
+	if b.closed {
+		return 0, errors.New("closed")
+	}
+	return b.lockedBuffer.Write(p)
+}
+
+func (b *closableBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestZapLogger_DedupSnapshot(t *testing.T) {
+	buf := &closableBuffer{}
+	logger, err := azap.NewLogger(t.Name(), options.WithOwnedWriter(buf), options.WithDedup(time.Hour, 0))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	state := &mutableStringer{s: "first"}
+	cause := errors.New("cause")
+	fields := []zap.Field{
+		zap.Stringer("state", state),
+		zap.Inline(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("inline", "kept")
+			return nil
+		})),
+		zap.Error(fmt.Errorf("wrapped, %w", cause)),
+	}
+	logger.Warn("refused", fields...)
+	logger.Warn("refused", fields...)
+	// the fields are snapshot on the first repeat
+	state.s = "changed"
+	logger.Warn("refused", fields...)
+	// the summary is flushed before the owned writer is closed
+	logger.Close()
+
+	entries := buf.entries(t)
+	if len(entries) != 2 || entries[1]["repeated"] != float64(2) || entries[1]["state"] != "first" {
+		t.Fatalf("summary must report the fields of the first repeat, %v", entries)
+	}
+	if entries[1]["inline"] != "kept" || entries[1]["error"] != "wrapped, cause" {
+		t.Fatalf("summary must keep the other fields as they are, %v", entries[1])
+	}
+}
+
+func TestZapLogger_DedupWriteError(t *testing.T) {
+	w := &failingWriter{}
+	logger, err := azap.NewLogger(t.Name(), options.WithWriter(w), options.WithDedup(time.Hour, 0))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	logger.Warn("refused")
+	logger.Warn("refused")
+	w.fail.Store(true)
+	if err := logger.Close(); err == nil || !strings.Contains(err.Error(), "injected write failure") {
+		t.Fatalf("write errors of the summaries must be returned, got %v", err)
+	}
+}
+
+// failingWriter fails the writes once fail is set.
+type failingWriter struct {
+	fail atomic.Bool
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.fail.Load() {
+		return 0, errors.New("injected write failure")
+	}
+	return len(p), nil
+}
@@ -0,0 +1,116 @@
+package azap
+
+import (
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// EntryLogger is implemented by the loggers of azap, for the adapters of other logging APIs,
+// e.g. log/slog, which know the caller and the time of an entry better than the logger.
+//
+// The level, time, message and caller of the entry are used, the logger name is filled by the logger.
+// The level rules, verbose and vmodule rules are applied, in which the caller of the entry is matched.
+// Entries above ErrorLevel are written without panicking or exiting.
+type EntryLogger interface {
+	// EntryEnabled reports whether the entry is logged, only the level, the time and the caller of the entry are used.
+	EntryEnabled(ent zapcore.Entry) bool
+	LogEntry(ent zapcore.Entry, fields ...zapcore.Field)
+}
+
+// EntryEnabled reports whether the entry is logged by EntryLogger if the logger implements it,
+// otherwise by Enabled of the logger.
+func EntryEnabled(l types.Logger, ent zapcore.Entry) bool {
+	if el, ok := l.(EntryLogger); ok {
+		return el.EntryEnabled(ent)
+	}
+	return l.Enabled(ent.Level)
+}
+
+// LogEntry logs the entry by EntryLogger if the logger implements it, otherwise by the logging method of its level,
+// in which case the time and the caller of the entry are lost.
+func LogEntry(l types.Logger, ent zapcore.Entry, fields ...zapcore.Field) {
+	if el, ok := l.(EntryLogger); ok {
+		el.LogEntry(ent, fields...)
+		return
+	}
+	switch {
+	case ent.Level <= zapcore.DebugLevel:
+		l.Debug(ent.Message, fields...)
+	case ent.Level == zapcore.InfoLevel:
+		l.Info(ent.Message, fields...)
+	case ent.Level == zapcore.WarnLevel:
+		l.Warn(ent.Message, fields...)
+	default:
+		l.Error(ent.Message, fields...)
+	}
+}
+
+// FatalEntryLogger is implemented by the loggers of azap, it logs an entry at FatalLevel by the fatal path
+// of the logger like Fatal does: the logger is closed and the fatal action is called, see options.WithFatalAction.
+type FatalEntryLogger interface {
+	LogFatalEntry(ent zapcore.Entry, fields ...zapcore.Field)
+}
+
+// LogFatalEntry logs the entry at FatalLevel by FatalEntryLogger if the logger implements it, otherwise by Fatal
+// of the logger, in which case the time and the caller of the entry are lost.
+func LogFatalEntry(l types.Logger, ent zapcore.Entry, fields ...zapcore.Field) {
+	if fl, ok := l.(FatalEntryLogger); ok {
+		fl.LogFatalEntry(ent, fields...)
+		return
+	}
+	l.Fatal(ent.Message, fields...)
+}
+
+var (
+	_ EntryLogger      = (*zapLogger)(nil)
+	_ EntryLogger      = (*verboseZapLogger)(nil)
+	_ EntryLogger      = LoggerWithCtx{}
+	_ FatalEntryLogger = (*zapLogger)(nil)
+	_ FatalEntryLogger = LoggerWithCtx{}
+)
+
+func (l *zapLogger) EntryEnabled(ent zapcore.Entry) bool {
+	return l.levels.enabled(l.Name(), ent.Level)
+}
+
+func (l *zapLogger) LogEntry(ent zapcore.Entry, fields ...zapcore.Field) {
+	ent.LoggerName = l.Name()
+	if l.disableCaller {
+		ent.Caller = zapcore.EntryCaller{}
+	}
+	if ce := l.Logger.Core().Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+func (l *zapLogger) LogFatalEntry(ent zapcore.Entry, fields ...zapcore.Field) {
+	ent.Level = zapcore.FatalLevel
+	ent.LoggerName = l.Name()
+	if l.disableCaller {
+		ent.Caller = zapcore.EntryCaller{}
+	}
+	l.Logger.Core().Check(ent, nil).After(ent, l.fatal).Write(fields...)
+}
+
+func (l *verboseZapLogger) EntryEnabled(ent zapcore.Entry) bool {
+	return l.verbosity.enabledAt(l.verbose, ent.Caller.PC) && l.zapLogger.EntryEnabled(ent)
+}
+
+func (l *verboseZapLogger) LogEntry(ent zapcore.Entry, fields ...zapcore.Field) {
+	if l.verbosity.enabledAt(l.verbose, ent.Caller.PC) {
+		l.zapLogger.LogEntry(ent, fields...)
+	}
+}
+
+func (l LoggerWithCtx) EntryEnabled(ent zapcore.Entry) bool {
+	return EntryEnabled(l.ZapLogger, ent)
+}
+
+func (l LoggerWithCtx) LogEntry(ent zapcore.Entry, fields ...zapcore.Field) {
+	LogEntry(l.ZapLogger, ent, append(fields, l.buildFields(l.ctx)...)...)
+}
+
+func (l LoggerWithCtx) LogFatalEntry(ent zapcore.Entry, fields ...zapcore.Field) {
+	LogFatalEntry(l.ZapLogger, ent, append(fields, l.buildFields(l.ctx)...)...)
+}
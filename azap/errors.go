@@ -0,0 +1,173 @@
+package azap
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxErrorCauses limits the causes walked in an error tree.
+const maxErrorCauses = 32
+
+// errorCore encodes the error fields by richError, see options.WithRichErrors.
+type errorCore struct {
+	zapcore.Core
+}
+
+func (c *errorCore) With(fields []zapcore.Field) zapcore.Core {
+	return &errorCore{Core: c.Core.With(richErrorFields(fields))}
+}
+
+func (c *errorCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write checks the entry again by the wrapped core, which may be a tee of cores with different levels.
+func (c *errorCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return writeChecked(c.Core, ent, richErrorFields(fields))
+}
+
+func richErrorFields(fields []zapcore.Field) []zapcore.Field {
+	var rich []zapcore.Field
+	for i, f := range fields {
+		if f.Type != zapcore.ErrorType {
+			continue
+		}
+		err, ok := f.Interface.(error)
+		if !ok {
+			continue
+		}
+		// fields belong to the caller
+		if rich == nil {
+			rich = append([]zapcore.Field(nil), fields...)
+		}
+		rich[i] = zap.Inline(richError{key: f.Key, err: err})
+	}
+	if rich == nil {
+		return fields
+	}
+	return rich
+}
+
+// richError encodes an error as `<key>`, the type of the error as `<key>Type`, the causes walked by
+// errors.Unwrap and errors.Join as `<key>Causes`, and the stack carried by the innermost cause as `<key>Stack`.
+// A stack is carried by the method `StackTrace()` returning a slice of program counters, e.g. errors of
+// github.com/pkg/errors and alog.Errorf.
+type richError struct {
+	key string
+	err error
+}
+
+func (e richError) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			enc.AddString(e.key, fmt.Sprintf("PANIC=%v", r))
+		}
+	}()
+
+	enc.AddString(e.key, e.err.Error())
+	enc.AddString(e.key+"Type", fmt.Sprintf("%T", e.err))
+
+	var causes errorCauses
+	var stack []uintptr
+	stackDepth := -1
+	walkError(e.err, func(err error, depth int) {
+		if depth > 0 {
+			causes = append(causes, err)
+		}
+		if s := stackOf(err); len(s) > 0 && depth > stackDepth {
+			stack, stackDepth = s, depth
+		}
+	})
+	if len(causes) > 0 {
+		err = multierr.Append(err, enc.AddArray(e.key+"Causes", causes))
+	}
+	if len(stack) > 0 {
+		err = multierr.Append(err, enc.AddArray(e.key+"Stack", stackFrames(stack)))
+	}
+	return err
+}
+
+// walkError walks the error tree in depth-first order, at most maxErrorCauses errors are visited.
+func walkError(err error, visit func(err error, depth int)) {
+	visited := 0
+	var walk func(err error, depth int)
+	walk = func(err error, depth int) {
+		if err == nil || visited >= maxErrorCauses {
+			return
+		}
+		visited++
+		visit(err, depth)
+		switch u := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, cause := range u.Unwrap() {
+				walk(cause, depth+1)
+			}
+		default:
+			walk(errors.Unwrap(err), depth+1)
+		}
+	}
+	walk(err, 0)
+}
+
+// stackOf returns the stack of err, the method StackTrace may return a named type like errors.StackTrace of pkg/errors.
+func stackOf(err error) []uintptr {
+	if s, ok := err.(interface{ StackTrace() []uintptr }); ok {
+		return s.StackTrace()
+	}
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return nil
+	}
+	if t := m.Type().Out(0); t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	v := m.Call(nil)[0]
+	stack := make([]uintptr, v.Len())
+	for i := range stack {
+		stack[i] = uintptr(v.Index(i).Uint())
+	}
+	return stack
+}
+
+type errorCauses []error
+
+func (c errorCauses) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	var err error
+	for _, cause := range c {
+		cause := cause
+		err = multierr.Append(err, enc.AppendObject(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("type", fmt.Sprintf("%T", cause))
+			enc.AddString("msg", cause.Error())
+			return nil
+		})))
+	}
+	return err
+}
+
+type stackFrames []uintptr
+
+func (s stackFrames) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	var err error
+	frames := runtime.CallersFrames(s)
+	for {
+		frame, more := frames.Next()
+		err = multierr.Append(err, enc.AppendObject(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("function", frame.Function)
+			enc.AddString("file", frame.File)
+			enc.AddInt("line", frame.Line)
+			return nil
+		})))
+		if !more {
+			return err
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package azap
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// fatalHook closes the root logger after a fatal entry is written, so that the entry reaches the writers,
+// then calls the fatal action. It is shared by the clones of the root logger.
+type fatalHook struct {
+	logger *zapLogger
+	action types.FatalAction
+}
+
+func (h *fatalHook) OnWrite(ce *zapcore.CheckedEntry, _ []zapcore.Field) {
+	if err := h.logger.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v close logger failed, %v\n", time.Now().UTC(), err)
+	}
+	if h.action == nil {
+		os.Exit(1)
+	}
+	h.action(ce.Entry)
+	panic("fatal: " + ce.Entry.Message)
+}
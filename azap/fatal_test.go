@@ -0,0 +1,63 @@
+package azap_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestZapLogger_FatalAction(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("ALOG_FILE_DIR", dir)
+
+	var hooked atomic.Int32
+	var fatal zapcore.Entry
+	logger, err := azap.NewLogger(t.Name(),
+		options.WithEnv(""),
+		options.WithAsyncHook(zapcore.ErrorLevel, func(zapcore.Entry, []zapcore.Field) { hooked.Add(1) }, 10),
+		options.WithFatalAction(func(ent zapcore.Entry) { fatal = ent }),
+	)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	recovered := func() (r interface{}) {
+		defer func() { r = recover() }()
+		logger.Error("queued")
+		logger.Named("child").Fatal("bye")
+		return nil
+	}()
+	if recovered != "fatal: bye" || fatal.Message != "bye" {
+		t.Fatalf("fatal action is not called, recovered %v, entry %+v", recovered, fatal)
+	}
+	if hooked.Load() != 2 {
+		t.Fatalf("async hook must be drained before the fatal action, got %d", hooked.Load())
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %v", files)
+	}
+	if b, _ := os.ReadFile(files[0]); !strings.Contains(string(b), `"msg":"bye"`) {
+		t.Fatalf("fatal entry must be flushed, got %s", b)
+	}
+}
+
+func TestZapLogger_FatalPanic(t *testing.T) {
+	logger, err := azap.NewLogger(t.Name(), options.WithWriter(os.Stderr), options.WithFatalPanic())
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	defer func() {
+		if r := recover(); r != "fatal: bye" {
+			t.Fatalf("expected panic of fatal, got %v", r)
+		}
+	}()
+	logger.Sugar().Fatalf("%s", "bye")
+}
@@ -0,0 +1,162 @@
+package azap
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type hookConfig struct {
+	minLevel zapcore.Level
+	hook     types.LogHook
+	// queueSize is the size of the queue of an async hook, 0 for a sync hook
+	queueSize int
+}
+
+// hookCore calls the hook with the entries at or above its level, the panics of the hook are recovered.
+type hookCore struct {
+	minLevel zapcore.Level
+	hook     types.LogHook
+	fields   []zapcore.Field
+	async    *asyncHook
+}
+
+func newHookCore(c hookConfig) *hookCore {
+	core := &hookCore{
+		minLevel: c.minLevel,
+		hook:     c.hook,
+	}
+	if c.queueSize > 0 {
+		core.async = newAsyncHook(c.hook, c.queueSize)
+	}
+	return core
+}
+
+func (c *hookCore) Enabled(level zapcore.Level) bool {
+	return level >= c.minLevel
+}
+
+func (c *hookCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	return &clone
+}
+
+func (c *hookCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *hookCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(append(all, c.fields...), fields...)
+	if c.async != nil {
+		c.async.deliver(ent, all)
+		return nil
+	}
+	return callHook(c.hook, ent, all)
+}
+
+func (c *hookCore) Sync() error {
+	return nil
+}
+
+func callHook(hook types.LogHook, ent zapcore.Entry, fields []zapcore.Field) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("hook panicked, %v", r)
+		}
+	}()
+	hook(ent, fields)
+	return nil
+}
+
+const (
+	// asyncHookUrgentWait bounds the wait for room in the queue of the entries above ERROR,
+	// which are not dropped at once as the process may exit after them.
+	asyncHookUrgentWait = 100 * time.Millisecond
+	// asyncHookCloseTimeout bounds the wait of Close for the queued entries.
+	asyncHookCloseTimeout = time.Second
+)
+
+type hookEntry struct {
+	ent    zapcore.Entry
+	fields []zapcore.Field
+}
+
+// asyncHook calls the hook in its own goroutine, entries are dropped if the queue is full.
+type asyncHook struct {
+	hook   types.LogHook
+	queue  chan hookEntry
+	done   chan struct{}
+	mutex  sync.RWMutex
+	closed bool
+}
+
+func newAsyncHook(hook types.LogHook, queueSize int) *asyncHook {
+	h := &asyncHook{
+		hook:  hook,
+		queue: make(chan hookEntry, queueSize),
+		done:  make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+func (h *asyncHook) run() {
+	defer close(h.done)
+	for e := range h.queue {
+		if err := callHook(h.hook, e.ent, e.fields); err != nil {
+			fmt.Fprintf(os.Stderr, "%v %v\n", time.Now().UTC(), err)
+		}
+	}
+}
+
+func (h *asyncHook) deliver(ent zapcore.Entry, fields []zapcore.Field) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if h.closed {
+		return
+	}
+	e := hookEntry{ent: ent, fields: fields}
+	if ent.Level <= zapcore.ErrorLevel {
+		select {
+		case h.queue <- e:
+		default:
+		}
+		return
+	}
+	timer := time.NewTimer(asyncHookUrgentWait)
+	defer timer.Stop()
+	select {
+	case h.queue <- e:
+	case <-timer.C:
+	}
+}
+
+// Close stops accepting entries and waits for the queued ones, a hook not finished in time is reported.
+func (h *asyncHook) Close() error {
+	h.mutex.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.mutex.Unlock()
+
+	timer := time.NewTimer(asyncHookCloseTimeout)
+	defer timer.Stop()
+	select {
+	case <-h.done:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("async hook did not finish in %v, %d queued entries left", asyncHookCloseTimeout, len(h.queue))
+	}
+}
@@ -0,0 +1,126 @@
+package azap_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestZapLogger_Hook(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	var messages []string
+	logger, err := azap.NewLogger(t.Name(),
+		options.WithWriter(buf),
+		options.WithHook(zapcore.WarnLevel, func(ent zapcore.Entry, fields []zapcore.Field) {
+			messages = append(messages, ent.Message)
+			for _, f := range fields {
+				messages = append(messages, f.Key)
+			}
+		}),
+		options.WithHook(zapcore.ErrorLevel, func(ent zapcore.Entry, fields []zapcore.Field) {
+			panic("broken hook")
+		}),
+	)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	logger.Info("info")
+	logger.With(zap.String("k", "v")).Warn("warn", zap.Int("n", 1))
+	logger.Error("error")
+	logger.Error("after panic")
+
+	if got := strings.Join(messages, ","); got != "warn,k,n,error,after panic" {
+		t.Fatalf("unexpected hooked entries, %s", got)
+	}
+	if strings.Count(buf.String(), "\n") != 4 {
+		t.Fatalf("entries must be written regardless of hooks, got %s", buf.String())
+	}
+}
+
+func TestZapLogger_AsyncHook(t *testing.T) {
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	var messages []string
+	logger, err := azap.NewLogger(t.Name(),
+		options.WithWriter(bytes.NewBuffer(nil)),
+		options.WithAsyncHook(zapcore.InfoLevel, func(ent zapcore.Entry, fields []zapcore.Field) {
+			<-release
+			mutex.Lock()
+			defer mutex.Unlock()
+			messages = append(messages, ent.Message)
+		}, 1),
+	)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			logger.Info("blocked")
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a blocked async hook must not block the logging")
+	}
+
+	close(release)
+	if err := logger.Close(); err != nil {
+		t.Fatalf("close failed, %v", err)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	// at most the one being delivered and the one queued, the others are dropped
+	if len(messages) == 0 || len(messages) > 2 {
+		t.Fatalf("expected 1 or 2 delivered entries, got %d", len(messages))
+	}
+}
+
+func TestZapLogger_AsyncHookNeverBlocks(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	hang := func(zapcore.Entry, []zapcore.Field) { <-block }
+
+	var fatal bool
+	logger, err := azap.NewLogger(t.Name(),
+		options.WithWriter(bytes.NewBuffer(nil)),
+		options.WithAsyncHook(zapcore.InfoLevel, hang, 1),
+		options.WithFatalAction(func(zapcore.Entry) { fatal = true }),
+	)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	start := time.Now()
+	recovered := func() (r interface{}) {
+		defer func() { r = recover() }()
+		logger.Info("hanging")
+		logger.Panic("panic")
+		return nil
+	}()
+	func() {
+		defer func() { recover() }()
+		logger.Fatal("fatal")
+	}()
+	if recovered != "panic" || !fatal {
+		t.Fatalf("panic and fatal must return to their handling, recovered %v, fatal %v", recovered, fatal)
+	}
+	if err := logger.Close(); err == nil || !strings.Contains(err.Error(), "did not finish") {
+		t.Fatalf("hanging hook must be reported by Close, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("hanging hook blocked the logger for %v", elapsed)
+	}
+}
@@ -0,0 +1,183 @@
+package azap
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levelRule overrides the log level of the loggers whose name matches the pattern.
+type levelRule struct {
+	pattern  string
+	wildcard bool
+	level    zapcore.Level
+}
+
+// levelRules is an immutable set of rules, the lookups are cached by logger name.
+type levelRules struct {
+	spec  string
+	rules []levelRule
+	min   zapcore.Level
+	cache sync.Map // logger name -> levelLookup
+}
+
+type levelLookup struct {
+	level   zapcore.Level
+	matched bool
+}
+
+// parseLevelRules parses rules like `svc.db.*=debug,svc.http=warn`.
+//
+// A pattern without `*` matches the logger name exactly, and `*` matches any sequence of characters.
+// If several patterns match a logger, an exact pattern wins, then the longest one, then the last one.
+func parseLevelRules(spec string) (*levelRules, error) {
+	rules := &levelRules{spec: strings.TrimSpace(spec), min: zapcore.InvalidLevel}
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		pattern, text, ok := strings.Cut(item, "=")
+		if pattern = strings.TrimSpace(pattern); !ok || pattern == "" {
+			return nil, fmt.Errorf("bad level rule `%s`, expect pattern=level", item)
+		}
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(strings.TrimSpace(text))); err != nil {
+			return nil, fmt.Errorf("bad level rule `%s`, %w", item, err)
+		}
+		rules.rules = append(rules.rules, levelRule{
+			pattern:  pattern,
+			wildcard: strings.Contains(pattern, "*"),
+			level:    level,
+		})
+		if rules.min == zapcore.InvalidLevel || level < rules.min {
+			rules.min = level
+		}
+	}
+	return rules, nil
+}
+
+// lookup returns the level of the most specific rule matching the logger name.
+func (r *levelRules) lookup(name string) levelLookup {
+	if len(r.rules) == 0 {
+		return levelLookup{}
+	}
+	if v, ok := r.cache.Load(name); ok {
+		return v.(levelLookup)
+	}
+
+	var best *levelRule
+	for i := range r.rules {
+		rule := &r.rules[i]
+		if !matchPattern(rule.pattern, name) {
+			continue
+		}
+		if best == nil ||
+			best.wildcard && !rule.wildcard ||
+			best.wildcard == rule.wildcard && len(rule.pattern) >= len(best.pattern) {
+			best = rule
+		}
+	}
+	var result levelLookup
+	if best != nil {
+		result = levelLookup{level: best.level, matched: true}
+	}
+	r.cache.Store(name, result)
+	return result
+}
+
+// matchPattern reports whether name matches pattern, in which `*` matches any sequence of characters.
+func matchPattern(pattern, name string) bool {
+	p, n, star, next := 0, 0, -1, 0
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, n
+			p++
+		case p < len(pattern) && pattern[p] == name[n]:
+			p++
+			n++
+		case star >= 0:
+			next++
+			p, n = star+1, next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+var _ zapcore.Core = (*levelCore)(nil)
+
+// levelCore filters entries by the level of their logger, which is either decided by
+// the level rules or falls back to the logger level.
+type levelCore struct {
+	zapcore.Core
+	state *levelState
+}
+
+// levelState is shared by a logger and all of its clones.
+type levelState struct {
+	level zap.AtomicLevel
+	rules atomic.Pointer[levelRules]
+}
+
+func newLevelState(level zap.AtomicLevel) *levelState {
+	state := &levelState{level: level}
+	state.rules.Store(&levelRules{min: zapcore.InvalidLevel})
+	return state
+}
+
+func (s *levelState) enabled(name string, level zapcore.Level) bool {
+	if lookup := s.rules.Load().lookup(name); lookup.matched {
+		return lookup.level.Enabled(level)
+	}
+	return s.level.Enabled(level)
+}
+
+func (s *levelState) minLevel() zapcore.Level {
+	level := s.level.Level()
+	if min := s.rules.Load().min; min != zapcore.InvalidLevel && min < level {
+		level = min
+	}
+	return level
+}
+
+func (s *levelState) reload(spec string) error {
+	rules, err := parseLevelRules(spec)
+	if err != nil {
+		return err
+	}
+	s.rules.Store(rules)
+	return nil
+}
+
+// Enabled reports whether any logger may log at the level, the logger name is checked in Check.
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.state.minLevel().Enabled(level)
+}
+
+// Level implements the interface used by zapcore.LevelOf.
+func (c *levelCore) Level() zapcore.Level {
+	return c.state.minLevel()
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{
+		Core:  c.Core.With(fields),
+		state: c.state,
+	}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.state.enabled(ent.LoggerName, ent.Level) {
+		return c.Core.Check(ent, ce)
+	}
+	return ce
+}
@@ -0,0 +1,77 @@
+package azap_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestZapLogger_LogLevels(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := azap.NewLogger("svc",
+		options.WithWriter(buf),
+		options.WithLogLevels("svc.db.*=debug, svc.http=warn, svc.db.slow=error"),
+	)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	db := logger.Named("db").Named("conn")
+	slow := logger.Named("db").Named("slow")
+	http := logger.Named("http")
+
+	logger.Debug("root-debug")
+	logger.Info("root-info")
+	db.Debug("db-debug")
+	slow.Warn("slow-warn")
+	slow.Error("slow-error")
+	http.Info("http-info")
+	http.Named("client").Info("http-client-info")
+
+	for _, msg := range []string{"root-info", "db-debug", "slow-error", "http-client-info"} {
+		if !strings.Contains(buf.String(), msg) {
+			t.Fatalf("`%s` is missing, got %s", msg, buf.String())
+		}
+	}
+	for _, msg := range []string{"root-debug", "slow-warn", "http-info"} {
+		if strings.Contains(buf.String(), msg) {
+			t.Fatalf("`%s` must not be printed, got %s", msg, buf.String())
+		}
+	}
+	if !db.Enabled(zapcore.DebugLevel) || http.Enabled(zapcore.InfoLevel) || logger.Enabled(zapcore.DebugLevel) {
+		t.Fatal("Enabled does not follow the level rules")
+	}
+
+	// hot reload
+	buf.Reset()
+	if err := logger.HotReloadLogLevels("svc.http=debug"); err != nil {
+		t.Fatalf("reload log levels failed, %v", err)
+	}
+	db.Debug("db-debug")
+	http.Debug("http-debug")
+	if strings.Contains(buf.String(), "db-debug") || !strings.Contains(buf.String(), "http-debug") {
+		t.Fatalf("reloaded rules not work, got %s", buf.String())
+	}
+
+	// loggers without rules follow the log level
+	buf.Reset()
+	if err := logger.HotReloadLogLevel(zapcore.DebugLevel); err != nil {
+		t.Fatalf("reload log level failed, %v", err)
+	}
+	db.Debug("db-debug")
+	if !strings.Contains(buf.String(), "db-debug") {
+		t.Fatalf("log level not work, got %s", buf.String())
+	}
+
+	if err := logger.HotReloadLogLevels("svc.db"); err == nil {
+		t.Fatal("bad rules must be rejected")
+	}
+	if _, err := azap.NewLogger("svc", options.WithLogLevels("svc=verbose")); err == nil {
+		t.Fatal("bad rules must be rejected")
+	}
+}
@@ -0,0 +1,92 @@
+package azap
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func (l *zapLogger) Recover(ctx context.Context, opts ...types.RecoverOption) {
+	if r := recover(); r != nil {
+		LogPanic(l, ctx, r, opts...)
+	}
+}
+
+// Recover logs the panics regardless of the verbose.
+func (l *verboseZapLogger) Recover(ctx context.Context, opts ...types.RecoverOption) {
+	if r := recover(); r != nil {
+		LogPanic(l.zapLogger, ctx, r, opts...)
+	}
+}
+
+// Recover uses ctx of the logger if ctx is nil.
+func (l LoggerWithCtx) Recover(ctx context.Context, opts ...types.RecoverOption) {
+	if r := recover(); r != nil {
+		if ctx == nil {
+			ctx = l.ctx
+		}
+		LogPanic(l.ZapLogger, ctx, r, opts...)
+	}
+}
+
+// LogPanic logs the value recovered from a panic as Recover does, then repanics, exits or returns by the policy.
+// It is for the implementations of types.LogRecoverFunc, which must call recover in their Recover directly.
+func LogPanic(l types.Logger, ctx context.Context, recovered interface{}, opts ...types.RecoverOption) {
+	o := types.RecoverOptions{
+		Level:   zapcore.ErrorLevel,
+		Policy:  types.RecoverRepanic,
+		Message: "panic recovered",
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	fields := []zapcore.Field{zap.Any("panic", recovered)}
+	if ctx != nil {
+		fields = append(fields, ctxFields(ctx)...)
+	}
+	ent := zapcore.Entry{
+		Level:   o.Level,
+		Time:    time.Now(),
+		Message: o.Message,
+		Caller:  panicCaller(),
+		Stack:   string(debug.Stack()),
+	}
+	if o.Policy == types.RecoverExit {
+		// the fatal path closes the logger and calls the fatal action, repanic if it ever returns
+		LogFatalEntry(l, ent, fields...)
+		panic(recovered)
+	}
+	LogEntry(l, ent, fields...)
+
+	switch o.Policy {
+	case types.RecoverSwallow:
+	default:
+		panic(recovered)
+	}
+}
+
+// panicCaller returns the frame that panics, which is the first frame out of the runtime below runtime.gopanic.
+func panicCaller() zapcore.EntryCaller {
+	var pcs [64]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	panicking := false
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "runtime.gopanic" {
+			panicking = true
+		} else if panicking && !strings.HasPrefix(frame.Function, "runtime.") {
+			return zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, true)
+		}
+		if !more {
+			return zapcore.EntryCaller{}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package azap_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestZapLogger_Recover(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := azap.NewLogger(t.Name(), options.WithWriter(buf))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	ctx := context.WithValue(context.Background(), "request_id", "123456")
+
+	func() {
+		defer logger.V(3).(types.LogRecoverFunc).Recover(ctx, options.WithRecoverPolicy(types.RecoverSwallow), options.WithRecoverLevel(zapcore.FatalLevel))
+		var m map[string]int
+		m["boom"] = 1
+	}()
+	for _, s := range []string{`"level":"FATAL"`, `"msg":"panic recovered"`, `"panic":"assignment to entry in nil map"`,
+		`"request_id":"123456"`, `"stacktrace":"goroutine `, `"caller":"azap/recover_test.go:27"`} {
+		if !strings.Contains(buf.String(), s) {
+			t.Fatalf("`%s` is missing, got %s", s, buf.String())
+		}
+	}
+
+	buf.Reset()
+	recovered := func() (r interface{}) {
+		defer func() { r = recover() }()
+		defer azap.Ctx(ctx, logger).Recover(nil, options.WithRecoverMessage("handler panicked"))
+		panic("again")
+	}()
+	if recovered != "again" {
+		t.Fatalf("the panic must be repanicked, got %v", recovered)
+	}
+	for _, s := range []string{`"level":"ERROR"`, `"msg":"handler panicked"`, `"panic":"again"`, `"request_id":"123456"`} {
+		if !strings.Contains(buf.String(), s) {
+			t.Fatalf("`%s` is missing, got %s", s, buf.String())
+		}
+	}
+}
+
+func TestZapLogger_RecoverExit(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	var fatal zapcore.Entry
+	var hooked int
+	logger, err := azap.NewLogger(t.Name(),
+		options.WithWriter(buf),
+		options.WithHook(zapcore.FatalLevel, func(zapcore.Entry, []zapcore.Field) { hooked++ }),
+		options.WithFatalAction(func(ent zapcore.Entry) { fatal = ent }),
+	)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	ctx := context.WithValue(context.Background(), "request_id", "123456")
+
+	recovered := func() (r interface{}) {
+		defer func() { r = recover() }()
+		defer azap.Ctx(ctx, logger).Recover(nil, options.WithRecoverPolicy(types.RecoverExit))
+		panic("exit")
+	}()
+	if recovered != "fatal: panic recovered" || fatal.Level != zapcore.FatalLevel || hooked != 1 {
+		t.Fatalf("the fatal path is not taken, recovered %v, entry %+v, hooked %d", recovered, fatal, hooked)
+	}
+	for _, s := range []string{`"level":"FATAL"`, `"panic":"exit"`, `"request_id":"123456"`, `"caller":"azap/recover_test.go:`} {
+		if !strings.Contains(buf.String(), s) {
+			t.Fatalf("`%s` is missing, got %s", s, buf.String())
+		}
+	}
+}
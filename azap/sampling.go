@@ -0,0 +1,182 @@
+package azap
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// maxRateLimitKeys bounds the token buckets of a rateLimitCore, an arbitrary one is evicted if it is exceeded.
+const maxRateLimitKeys = 4096
+
+const levelCount = int(zapcore.FatalLevel-zapcore.DebugLevel) + 1
+
+// logStats counts the dropped entries by level, it is shared by a logger and all of its clones.
+type logStats struct {
+	sampled     [levelCount]atomic.Uint64
+	rateLimited [levelCount]atomic.Uint64
+}
+
+func countLevel(counters *[levelCount]atomic.Uint64, level zapcore.Level) {
+	if level >= zapcore.DebugLevel && level <= zapcore.FatalLevel {
+		counters[level-zapcore.DebugLevel].Add(1)
+	}
+}
+
+func (s *logStats) snapshot() types.LogStats {
+	stats := types.LogStats{
+		Sampled:     make(map[zapcore.Level]uint64),
+		RateLimited: make(map[zapcore.Level]uint64),
+	}
+	for i := 0; i < levelCount; i++ {
+		level := zapcore.DebugLevel + zapcore.Level(i)
+		if n := s.sampled[i].Load(); n > 0 {
+			stats.Sampled[level] = n
+		}
+		if n := s.rateLimited[i].Load(); n > 0 {
+			stats.RateLimited[level] = n
+		}
+	}
+	return stats
+}
+
+type samplingConfig struct {
+	tick       time.Duration
+	first      int
+	thereafter int
+}
+
+// samplingCore samples the entries by a zap sampler of their level, entries at the other levels are not sampled.
+type samplingCore struct {
+	zapcore.Core
+	samplers map[zapcore.Level]zapcore.Core
+}
+
+func newSamplingCore(core zapcore.Core, configs map[zapcore.Level]samplingConfig, stats *logStats) *samplingCore {
+	hook := zapcore.SamplerHook(func(ent zapcore.Entry, dec zapcore.SamplingDecision) {
+		if dec&zapcore.LogDropped != 0 {
+			countLevel(&stats.sampled, ent.Level)
+		}
+	})
+	samplers := make(map[zapcore.Level]zapcore.Core, len(configs))
+	for level, c := range configs {
+		samplers[level] = zapcore.NewSamplerWithOptions(core, c.tick, c.first, c.thereafter, hook)
+	}
+	return &samplingCore{Core: core, samplers: samplers}
+}
+
+func (c *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	samplers := make(map[zapcore.Level]zapcore.Core, len(c.samplers))
+	for level, s := range c.samplers {
+		samplers[level] = s.With(fields)
+	}
+	return &samplingCore{Core: c.Core.With(fields), samplers: samplers}
+}
+
+func (c *samplingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if s, ok := c.samplers[ent.Level]; ok {
+		return s.Check(ent, ce)
+	}
+	return c.Core.Check(ent, ce)
+}
+
+type rateLimitConfig struct {
+	key       types.RateLimitKey
+	perSecond float64
+	burst     int
+}
+
+// rateLimitCore drops the entries exceeding the token bucket of their call site or message,
+// entries at the levels without rate limits are not limited.
+type rateLimitCore struct {
+	zapcore.Core
+	state *rateLimitState
+}
+
+// rateLimitState is shared by a rateLimitCore and all of its clones.
+type rateLimitState struct {
+	configs map[zapcore.Level]rateLimitConfig
+	stats   *logStats
+
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimitCore(core zapcore.Core, configs map[zapcore.Level]rateLimitConfig, stats *logStats) *rateLimitCore {
+	return &rateLimitCore{
+		Core: core,
+		state: &rateLimitState{
+			configs: configs,
+			stats:   stats,
+			buckets: make(map[string]*tokenBucket),
+		},
+	}
+}
+
+func (c *rateLimitCore) With(fields []zapcore.Field) zapcore.Core {
+	return &rateLimitCore{Core: c.Core.With(fields), state: c.state}
+}
+
+// Check defers the decision to Write, as the caller of the entry is not known yet.
+func (c *rateLimitCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if _, ok := c.state.configs[ent.Level]; !ok {
+		return c.Core.Check(ent, ce)
+	}
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *rateLimitCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.state.allow(ent) {
+		countLevel(&c.state.stats.rateLimited, ent.Level)
+		return nil
+	}
+	return writeChecked(c.Core, ent, fields)
+}
+
+func (s *rateLimitState) allow(ent zapcore.Entry) bool {
+	cfg := s.configs[ent.Level]
+	key := fmt.Sprintf("%d\x00%s\x00%s", ent.Level, ent.LoggerName, ent.Message)
+	if cfg.key == types.RateLimitByCallSite && ent.Caller.Defined {
+		key = fmt.Sprintf("%d\x00%s:%d", ent.Level, ent.Caller.File, ent.Caller.Line)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) >= maxRateLimitKeys {
+			for k := range s.buckets {
+				delete(s.buckets, k)
+				break
+			}
+		}
+		b = &tokenBucket{tokens: float64(cfg.burst), last: ent.Time}
+		s.buckets[key] = b
+	}
+	if elapsed := ent.Time.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * cfg.perSecond
+		if b.tokens > float64(cfg.burst) {
+			b.tokens = float64(cfg.burst)
+		}
+		b.last = ent.Time
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
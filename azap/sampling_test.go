@@ -0,0 +1,89 @@
+package azap_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestZapLogger_Sampling(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := azap.NewLogger(t.Name(),
+		options.WithWriter(buf),
+		options.WithSampling(zapcore.InfoLevel, time.Hour, 2, 0),
+		options.WithSampling(zapcore.WarnLevel, 0, 0, 0),
+	)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	child := logger.Named("child")
+	for i := 0; i < 5; i++ {
+		child.Info("sampled")
+		child.Error("never sampled")
+		// a zero tick falls back to 1s, instead of resetting the counter on every entry
+		child.Warn("default tick")
+	}
+	if n := strings.Count(buf.String(), "sampled"); n != 7 {
+		t.Fatalf("expected 7 entries, got %d, %s", n, buf.String())
+	}
+	if n := strings.Count(buf.String(), "default tick"); n != 1 {
+		t.Fatalf("expected 1 entry with the default tick, got %d", n)
+	}
+	stats := logger.LogStats()
+	if stats.Sampled[zapcore.InfoLevel] != 3 || stats.Sampled[zapcore.WarnLevel] != 4 || len(stats.Sampled) != 2 || stats.Dropped() != 7 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestZapLogger_RateLimit(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := azap.NewLogger(t.Name(),
+		options.WithWriter(buf),
+		options.WithRateLimit(zapcore.WarnLevel, types.RateLimitByCallSite, 0.001, 2),
+		options.WithRateLimit(zapcore.InfoLevel, types.RateLimitByMessage, 0.001, 0),
+	)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		logger.Warn("by call site")
+	}
+	logger.Warn("by call site")
+	logger.Info("by message")
+	logger.With().Info("by message")
+	logger.Error("never limited")
+	logger.Error("never limited")
+
+	for msg, expected := range map[string]int{"by call site": 3, "by message": 1, "never limited": 2} {
+		if n := strings.Count(buf.String(), msg); n != expected {
+			t.Fatalf("expected %d entries of %s, got %d, %s", expected, msg, n, buf.String())
+		}
+	}
+	stats := logger.LogStats()
+	if stats.RateLimited[zapcore.WarnLevel] != 3 || stats.RateLimited[zapcore.InfoLevel] != 1 || stats.Dropped() != 4 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestZapLogger_SamplingBadOptions(t *testing.T) {
+	for name, opt := range map[string]options.LoggerOption{
+		"sample errors":  options.WithSampling(zapcore.ErrorLevel, time.Second, 1, 0),
+		"negative tick":  options.WithSampling(zapcore.InfoLevel, -time.Second, 1, 0),
+		"negative first": options.WithSampling(zapcore.InfoLevel, time.Second, -1, 0),
+		"negative after": options.WithSampling(zapcore.InfoLevel, time.Second, 1, -1),
+		"limit errors":   options.WithRateLimit(zapcore.ErrorLevel, types.RateLimitByMessage, 1, 1),
+		"zero rate":      options.WithRateLimit(zapcore.InfoLevel, types.RateLimitByMessage, 0, 1),
+		"negative burst": options.WithRateLimit(zapcore.InfoLevel, types.RateLimitByMessage, 1, -1),
+	} {
+		if _, err := azap.NewLogger(t.Name(), opt); err == nil {
+			t.Fatalf("%s must be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package azap
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/csh0101/alog/types"
+	"github.com/csh0101/alog/writers"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	settingLevel         = "level"
+	settingLevels        = "levels"
+	settingVerbose       = "verbose"
+	settingVModule       = "vmodule"
+	settingFormat        = "format"
+	settingDisableCaller = "disableCaller"
+	settingWriters       = "writers"
+)
+
+// settingEnvs are the environment variables without prefix, in the order of LogSettings.
+var settingEnvs = []struct {
+	name string
+	env  string
+}{
+	{settingLevel, "LEVEL"},
+	{settingLevels, "LEVELS"},
+	{settingVerbose, "VERBOSE"},
+	{settingVModule, "VMODULE"},
+	{settingFormat, "FORMAT"},
+	{settingDisableCaller, "DISABLE_CALLER"},
+	{settingWriters, "FILE_DIR"},
+}
+
+// logSettings records the sources of the settings, it is shared by a logger and all of its clones.
+type logSettings struct {
+	mutex     sync.Mutex
+	envPrefix string
+	sources   map[string]types.LogSettingSource
+}
+
+func newLogSettings() *logSettings {
+	return &logSettings{sources: make(map[string]types.LogSettingSource)}
+}
+
+func (s *logSettings) set(name string, source types.LogSettingSource) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sources[name] = source
+}
+
+func (l *zapLogger) LogEnvOption(prefix string) {
+	if prefix = strings.TrimSuffix(strings.TrimSpace(prefix), "_"); prefix == "" {
+		prefix = "ALOG"
+	}
+	l.settings.envPrefix = prefix
+}
+
+func (l *zapLogger) LogSettings() []types.LogSetting {
+	values := map[string]string{
+		settingLevel:         l.LogLevel().String(),
+		settingLevels:        l.LogLevels(),
+		settingVerbose:       strconv.Itoa(l.LogVerbose()),
+		settingVModule:       l.LogVModule(),
+		settingFormat:        l.encoding,
+		settingDisableCaller: strconv.FormatBool(l.disableCaller),
+		settingWriters:       l.describeWriters(),
+	}
+
+	l.settings.mutex.Lock()
+	defer l.settings.mutex.Unlock()
+
+	settings := make([]types.LogSetting, 0, len(settingEnvs))
+	for _, s := range settingEnvs {
+		setting := types.LogSetting{
+			Name:   s.name,
+			Value:  values[s.name],
+			Source: types.LogSettingFromDefault,
+		}
+		if source, ok := l.settings.sources[s.name]; ok {
+			setting.Source = source
+		}
+		if l.settings.envPrefix != "" {
+			setting.Env = l.settings.envPrefix + "_" + s.env
+		}
+		settings = append(settings, setting)
+	}
+	return settings
+}
+
+func (l *zapLogger) describeWriters() string {
+	if l.core != nil {
+		return "core"
+	}
+	names := make([]string, 0, len(l.writers))
+	for _, w := range l.writers {
+		switch w := w.(type) {
+		case *os.File:
+			names = append(names, strings.TrimPrefix(w.Name(), "/dev/"))
+		case *writers.FileWriter:
+			names = append(names, "file:"+w.Dir())
+		default:
+			names = append(names, fmt.Sprintf("%T", w))
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// applyEnv overrides the settings by the environment variables, if env is enabled by options.WithEnv.
+// FILE_DIR replaces the writers from options. A custom core set by options.WithCore has its own encoder and sinks,
+// so FORMAT and FILE_* are rejected with an error instead of being ignored.
+func (l *zapLogger) applyEnv() error {
+	prefix := l.settings.envPrefix
+	if prefix == "" {
+		return nil
+	}
+	lookup := func(name string) (string, bool) {
+		v := strings.TrimSpace(os.Getenv(prefix + "_" + name))
+		return v, v != ""
+	}
+	envError := func(name, v string, err error) error {
+		return fmt.Errorf("bad env %s_%s=%s, %w", prefix, name, v, err)
+	}
+
+	if v, ok := lookup("LEVEL"); ok {
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			return envError("LEVEL", v, err)
+		}
+		l.logLevel.SetLevel(level)
+		l.settings.set(settingLevel, types.LogSettingFromEnv)
+	}
+	if v, ok := lookup("LEVELS"); ok {
+		l.levelRules = v
+		l.settings.set(settingLevels, types.LogSettingFromEnv)
+	}
+	if v, ok := lookup("VERBOSE"); ok {
+		verbose, err := strconv.Atoi(v)
+		if err != nil || verbose < 0 {
+			return envError("VERBOSE", v, fmt.Errorf("expect a non-negative integer"))
+		}
+		l.verbosity.filter.Store(int32(verbose))
+		l.settings.set(settingVerbose, types.LogSettingFromEnv)
+	}
+	if v, ok := lookup("VMODULE"); ok {
+		l.vmodule = v
+		l.settings.set(settingVModule, types.LogSettingFromEnv)
+	}
+	if v, ok := lookup("FORMAT"); ok {
+		if l.core != nil {
+			return envError("FORMAT", v, fmt.Errorf("can not apply to a logger with a custom core"))
+		}
+		switch v {
+		case "json", "console":
+			l.encoding = v
+		default:
+			return envError("FORMAT", v, fmt.Errorf("expect json or console"))
+		}
+		l.settings.set(settingFormat, types.LogSettingFromEnv)
+	}
+	if v, ok := lookup("DISABLE_CALLER"); ok {
+		disableCaller, err := strconv.ParseBool(v)
+		if err != nil {
+			return envError("DISABLE_CALLER", v, err)
+		}
+		l.disableCaller = disableCaller
+		l.settings.set(settingDisableCaller, types.LogSettingFromEnv)
+	}
+
+	var opts []writers.FileWriterOption
+	if v, ok := lookup("FILE_PREFIX"); ok {
+		opts = append(opts, writers.WithFilePrefix(v))
+	}
+	if v, ok := lookup("FILE_EXT"); ok {
+		opts = append(opts, writers.WithFileExt(v))
+	}
+	if v, ok := lookup("FILE_MAX_SIZE"); ok {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || size <= 0 {
+			return envError("FILE_MAX_SIZE", v, fmt.Errorf("expect a positive number of bytes"))
+		}
+		opts = append(opts, writers.WithFileMaxSizeInBytes(size))
+	}
+	if v, ok := lookup("FILE_RETENTION"); ok {
+		retention, err := time.ParseDuration(v)
+		if err != nil {
+			return envError("FILE_RETENTION", v, err)
+		}
+		opts = append(opts, writers.WithFileRetention(retention))
+	}
+	if v, ok := lookup("FILE_TOTAL_COUNT_LIMIT"); ok {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return envError("FILE_TOTAL_COUNT_LIMIT", v, err)
+		}
+		opts = append(opts, writers.WithFileTotalCountLimit(limit))
+	}
+	if v, ok := lookup("FILE_MODE"); ok {
+		mode, err := strconv.ParseUint(v, 8, 32)
+		if err != nil {
+			return envError("FILE_MODE", v, fmt.Errorf("expect octal like 0640"))
+		}
+		opts = append(opts, writers.WithFileMode(os.FileMode(mode)))
+	}
+	dir, ok := lookup("FILE_DIR")
+	// a custom core writes to its own sinks, e.g. the logger of a config file
+	if l.core != nil && (ok || len(opts) > 0) {
+		return fmt.Errorf("env %s_FILE_* can not apply to a logger with a custom core", prefix)
+	}
+	if !ok {
+		if len(opts) > 0 {
+			return fmt.Errorf("env %s_FILE_DIR is required by the other %s_FILE_* variables", prefix, prefix)
+		}
+		return nil
+	}
+	w, err := writers.NewFileWriter(dir, opts...)
+	if err != nil {
+		return envError("FILE_DIR", dir, err)
+	}
+	// the file replaces the writers from options, like the other settings overridden by env
+	l.writers = []io.Writer{w}
+	l.owned = append(l.owned, ownedWriter(w))
+	l.settings.set(settingWriters, types.LogSettingFromEnv)
+	return nil
+}
@@ -0,0 +1,149 @@
+package azap_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestZapLogger_Env(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SVC_LOG_LEVEL", "debug")
+	t.Setenv("SVC_LOG_FORMAT", "console")
+	t.Setenv("SVC_LOG_VERBOSE", "")
+	t.Setenv("SVC_LOG_FILE_DIR", dir)
+	t.Setenv("SVC_LOG_FILE_PREFIX", "svc")
+	t.Setenv("SVC_LOG_FILE_MODE", "0600")
+
+	buf := bytes.NewBuffer(nil)
+	logger, err := azap.NewLogger(t.Name(),
+		options.WithEnv("SVC_LOG_"),
+		options.WithLogLevel(zapcore.WarnLevel),
+		options.WithVerboseFilter(2),
+		options.WithWriter(buf),
+	)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	logger.Debug("debug-from-env")
+	if err := logger.HotReloadLogLevels("svc=info"); err != nil {
+		t.Fatalf("reload levels failed, %v", err)
+	}
+
+	settings := make(map[string]types.LogSetting)
+	for _, s := range logger.LogSettings() {
+		settings[s.Name] = s
+	}
+	for name, expected := range map[string]types.LogSetting{
+		"level":         {Value: "debug", Source: types.LogSettingFromEnv, Env: "SVC_LOG_LEVEL"},
+		"levels":        {Value: "svc=info", Source: types.LogSettingFromHotReload, Env: "SVC_LOG_LEVELS"},
+		"verbose":       {Value: "2", Source: types.LogSettingFromOption, Env: "SVC_LOG_VERBOSE"},
+		"vmodule":       {Value: "", Source: types.LogSettingFromDefault, Env: "SVC_LOG_VMODULE"},
+		"format":        {Value: "console", Source: types.LogSettingFromEnv, Env: "SVC_LOG_FORMAT"},
+		"disableCaller": {Value: "false", Source: types.LogSettingFromDefault, Env: "SVC_LOG_DISABLE_CALLER"},
+		"writers":       {Value: "file:" + dir, Source: types.LogSettingFromEnv, Env: "SVC_LOG_FILE_DIR"},
+	} {
+		expected.Name = name
+		if settings[name] != expected {
+			t.Fatalf("setting %s mismatch, expected %+v, got %+v", name, expected, settings[name])
+		}
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("close failed, %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("writers from options must be overridden, got %s", buf.String())
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "svc-*"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %v", files)
+	}
+	b, _ := os.ReadFile(files[0])
+	info, _ := os.Stat(files[0])
+	if !strings.Contains(string(b), "\tDEBUG\t") || !strings.Contains(string(b), "debug-from-env") || info.Mode().Perm() != 0600 {
+		t.Fatalf("unexpected file %v, %s", info.Mode(), b)
+	}
+}
+
+func TestZapLogger_EnvWithCore(t *testing.T) {
+	t.Setenv("ALOG_LEVEL", "debug")
+
+	buf := bytes.NewBuffer(nil)
+	newCore := func() zapcore.Core {
+		return zapcore.NewCore(zapcore.NewJSONEncoder(azap.NewEncoderConfig()), zapcore.AddSync(buf), zapcore.DebugLevel)
+	}
+	logger, err := azap.NewLogger(t.Name(), options.WithEnv(""), options.WithCore(newCore()))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	logger.Debug("to-core")
+	logger.Close()
+	if !strings.Contains(buf.String(), "to-core") {
+		t.Fatalf("entry is not written to the core, %s", buf.String())
+	}
+	for _, s := range logger.LogSettings() {
+		if s.Name == "writers" && (s.Value != "core" || s.Source != types.LogSettingFromOption) {
+			t.Fatalf("unexpected writers setting %+v", s)
+		}
+	}
+
+	dir := t.TempDir()
+	for env, v := range map[string]string{
+		"ALOG_FORMAT":        "console",
+		"ALOG_FILE_DIR":      dir,
+		"ALOG_FILE_PREFIX":   "svc",
+		"ALOG_FILE_MAX_SIZE": "1024",
+	} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, v)
+			if _, err := azap.NewLogger(t.Name(), options.WithEnv(""), options.WithCore(newCore())); err == nil {
+				t.Fatalf("env %s=%s must be rejected by a custom core", env, v)
+			}
+		})
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+		t.Fatalf("env file dir must not be opened for a custom core, got %v", files)
+	}
+}
+
+func TestZapLogger_EnvDisabled(t *testing.T) {
+	t.Setenv("ALOG_LEVEL", "debug")
+
+	logger, err := azap.NewLogger(t.Name(), options.WithWriter(bytes.NewBuffer(nil)))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	if logger.Enabled(zapcore.DebugLevel) {
+		t.Fatal("env must be ignored unless enabled")
+	}
+	for _, s := range logger.LogSettings() {
+		if s.Env != "" {
+			t.Fatalf("env of %s must be empty, got %s", s.Name, s.Env)
+		}
+	}
+
+	for env, v := range map[string]string{
+		"ALOG_LEVEL":          "loud",
+		"ALOG_VERBOSE":        "-1",
+		"ALOG_FORMAT":         "xml",
+		"ALOG_DISABLE_CALLER": "maybe",
+		"ALOG_FILE_PREFIX":    "svc",
+	} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv("ALOG_LEVEL", "")
+			t.Setenv(env, v)
+			if _, err := azap.NewLogger(t.Name(), options.WithEnv("")); err == nil {
+				t.Fatalf("bad env %s=%s must be rejected", env, v)
+			}
+		})
+	}
+}
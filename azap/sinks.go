@@ -0,0 +1,206 @@
+package azap
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewEncoderConfig returns the encoder config used by the loggers by default.
+func NewEncoderConfig() zapcore.EncoderConfig {
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+	return encoderConfig
+}
+
+// Sink is a destination of logs with its own encoder and level.
+type Sink struct {
+	Writer  zapcore.WriteSyncer
+	Encoder zapcore.Encoder
+	// Level filters the entries written to the sink after the logger level, nil accepts all.
+	Level zapcore.LevelEnabler
+}
+
+// Sinks is a set of sinks that can be swapped at runtime. An entry is encoded once
+// for the sinks sharing the same encoder. Use Core to write logs into it, e.g. by options.WithCore.
+type Sinks struct {
+	mutex sync.Mutex
+	set   atomic.Pointer[sinkSet]
+}
+
+// sinkSet is a generation of sinks. Its read lock is held while an entry is written to it,
+// so the writes in flight are drained by taking the write lock once it is replaced.
+type sinkSet struct {
+	mutex    sync.RWMutex
+	replaced bool
+	gen      uint64
+	sinks    []Sink
+}
+
+// NewSinks new a set of sinks.
+func NewSinks(sinks ...Sink) *Sinks {
+	s := &Sinks{}
+	s.set.Store(&sinkSet{sinks: sinks})
+	return s
+}
+
+// Swap replaces the sinks and returns the replaced ones. Entries being written to the replaced
+// sinks are finished before it returns, so they can be synced and closed by the caller safely.
+// Entries logged meanwhile are written to the new sinks without waiting.
+func (s *Sinks) Swap(sinks ...Sink) []Sink {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	old := s.set.Load()
+	s.set.Store(&sinkSet{gen: old.gen + 1, sinks: sinks})
+
+	old.mutex.Lock()
+	old.replaced = true
+	old.mutex.Unlock()
+	return old.sinks
+}
+
+// acquire returns the current generation of sinks with its read lock held.
+func (s *Sinks) acquire() *sinkSet {
+	for {
+		set := s.set.Load()
+		set.mutex.RLock()
+		if !set.replaced {
+			return set
+		}
+		set.mutex.RUnlock()
+	}
+}
+
+// Core returns a core writing to the sinks.
+func (s *Sinks) Core() zapcore.Core {
+	return &sinkCore{sinks: s}
+}
+
+var _ zapcore.Core = (*sinkCore)(nil)
+
+type sinkCore struct {
+	sinks    *Sinks
+	fields   []zapcore.Field
+	encoders atomic.Pointer[sinkEncoders]
+}
+
+// sinkEncoders caches the encoders with the context fields added, for a generation of sinks.
+type sinkEncoders struct {
+	gen      uint64
+	encoders []zapcore.Encoder
+	// groups[i] is the index of the first sink sharing the encoder with sink i
+	groups []int
+}
+
+func (c *sinkCore) Enabled(level zapcore.Level) bool {
+	for _, sink := range c.sinks.set.Load().sinks {
+		if sink.Level == nil || sink.Level.Enabled(level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *sinkCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+	return &sinkCore{sinks: c.sinks, fields: all}
+}
+
+func (c *sinkCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *sinkCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	set := c.sinks.acquire()
+	defer set.mutex.RUnlock()
+
+	encoders := c.sinkEncoders(set)
+	var err error
+	encoded := make(map[int]*buffer.Buffer, 1)
+	defer func() {
+		for _, buf := range encoded {
+			buf.Free()
+		}
+	}()
+	for i, sink := range set.sinks {
+		if sink.Level != nil && !sink.Level.Enabled(ent.Level) {
+			continue
+		}
+		group := encoders.groups[i]
+		buf, ok := encoded[group]
+		if !ok {
+			var encodeErr error
+			if buf, encodeErr = encoders.encoders[group].EncodeEntry(ent, fields); encodeErr != nil {
+				err = multierr.Append(err, encodeErr)
+				continue
+			}
+			encoded[group] = buf
+		}
+		if _, writeErr := sink.Writer.Write(buf.Bytes()); writeErr != nil {
+			err = multierr.Append(err, writeErr)
+		}
+		if ent.Level > zapcore.ErrorLevel {
+			err = multierr.Append(err, sink.Writer.Sync())
+		}
+	}
+	return err
+}
+
+func (c *sinkCore) Sync() error {
+	set := c.sinks.acquire()
+	defer set.mutex.RUnlock()
+
+	var err error
+	for _, sink := range set.sinks {
+		err = multierr.Append(err, sink.Writer.Sync())
+	}
+	return err
+}
+
+// sinkEncoders returns the encoders of a generation of sinks.
+func (c *sinkCore) sinkEncoders(set *sinkSet) *sinkEncoders {
+	if encoders := c.encoders.Load(); encoders != nil && encoders.gen == set.gen {
+		return encoders
+	}
+
+	encoders := &sinkEncoders{
+		gen:      set.gen,
+		encoders: make([]zapcore.Encoder, len(set.sinks)),
+		groups:   make([]int, len(set.sinks)),
+	}
+	for i, sink := range set.sinks {
+		encoders.groups[i] = i
+		for j := 0; j < i; j++ {
+			if sameEncoder(sink.Encoder, set.sinks[j].Encoder) {
+				encoders.groups[i] = encoders.groups[j]
+				break
+			}
+		}
+		if encoders.groups[i] != i {
+			continue
+		}
+		enc := sink.Encoder.Clone()
+		for _, field := range c.fields {
+			field.AddTo(enc)
+		}
+		encoders.encoders[i] = enc
+	}
+	c.encoders.Store(encoders)
+	return encoders
+}
+
+func sameEncoder(a, b zapcore.Encoder) bool {
+	return reflect.TypeOf(a) == reflect.TypeOf(b) && reflect.TypeOf(a).Comparable() && a == b
+}
@@ -0,0 +1,103 @@
+package azap_test
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSinks(t *testing.T) {
+	var all, errs, next bytes.Buffer
+	encoder := zapcore.NewJSONEncoder(azap.NewEncoderConfig())
+	sinks := azap.NewSinks(
+		azap.Sink{Writer: zapcore.AddSync(&all), Encoder: encoder},
+		azap.Sink{Writer: zapcore.AddSync(&errs), Encoder: encoder, Level: zapcore.ErrorLevel},
+	)
+	logger, err := azap.NewLogger(t.Name(), options.WithCore(sinks.Core()), options.WithLogLevel(zapcore.DebugLevel))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	ctxLogger := logger.With(zap.String("ctx", "kept"))
+
+	ctxLogger.Debug("debug-msg")
+	ctxLogger.Error("error-msg")
+	if !strings.Contains(all.String(), "debug-msg") || !strings.Contains(all.String(), "error-msg") ||
+		strings.Contains(errs.String(), "debug-msg") || !strings.Contains(errs.String(), "error-msg") {
+		t.Fatalf("sink levels not work, all %s, errors %s", all.String(), errs.String())
+	}
+
+	// context fields are kept across swaps
+	old := sinks.Swap(azap.Sink{Writer: zapcore.AddSync(&next), Encoder: zapcore.NewConsoleEncoder(azap.NewEncoderConfig())})
+	if len(old) != 2 {
+		t.Fatalf("expected 2 replaced sinks, got %d", len(old))
+	}
+	ctxLogger.Info("swapped-msg")
+	if !strings.Contains(next.String(), "swapped-msg") || !strings.Contains(next.String(), `{"ctx": "kept"}`) ||
+		strings.Contains(all.String(), "swapped-msg") {
+		t.Fatalf("swap not work, got %s", next.String())
+	}
+}
+
+func TestSinks_SwapNeverStalls(t *testing.T) {
+	blocked := &blockingWriter{entered: make(chan struct{}), release: make(chan struct{})}
+	encoder := zapcore.NewJSONEncoder(azap.NewEncoderConfig())
+	sinks := azap.NewSinks(azap.Sink{Writer: zapcore.AddSync(blocked), Encoder: encoder})
+	logger, err := azap.NewLogger(t.Name(), options.WithCore(sinks.Core()))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	go logger.Info("blocked-msg")
+	<-blocked.entered
+	swapped := make(chan struct{})
+	var next bytes.Buffer
+	go func() {
+		defer close(swapped)
+		sinks.Swap(azap.Sink{Writer: zapcore.AddSync(&next), Encoder: encoder})
+	}()
+
+	// entries logged during the swap go to the new sinks without waiting for the blocked write
+	logged := make(chan struct{})
+	go func() {
+		defer close(logged)
+		for !strings.Contains(next.String(), "next-msg") {
+			logger.Info("next-msg")
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	select {
+	case <-logged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logging is stalled by a swap")
+	}
+	select {
+	case <-swapped:
+		t.Fatal("swap returned before the write in flight finished")
+	default:
+	}
+	close(blocked.release)
+	<-swapped
+}
+
+// blockingWriter blocks the first write until release is closed.
+type blockingWriter struct {
+	first   atomic.Bool
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(b []byte) (int, error) {
+	if w.first.CompareAndSwap(false, true) {
+		close(w.entered)
+		<-w.release
+	}
+	return len(b), nil
+}
@@ -0,0 +1,163 @@
+package azap
+
+import (
+	"fmt"
+
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// sugarCallerSkip is the depth of sugaredLogger between the caller and the wrapped logger.
+const sugarCallerSkip = 2
+
+var _ types.SugaredLogger = (*sugaredLogger)(nil)
+
+// sugaredLogger formats the messages and fields, and logs them by the wrapped logger.
+// The logs are checked by Enabled before formatting, so V() and the level rules still apply.
+type sugaredLogger struct {
+	// base is returned by Desugar, logger is base with the caller skip of the sugared logger.
+	base   types.Logger
+	logger types.Logger
+}
+
+// NewSugaredLogger wraps logger, which must have sugarCallerSkip more caller skip than desugared, e.g. by AddCallerSkip.
+// It is for the implementations of types.Logger wrapping the loggers of azap, Desugar returns desugared.
+func NewSugaredLogger(desugared, logger types.Logger) types.SugaredLogger {
+	return newSugaredLogger(desugared, logger)
+}
+
+// Sugar returns the sugared logger of l by types.LogSugarFunc if l implements it, otherwise it wraps l,
+// in which case the caller is reported right only for the loggers of azap.
+func Sugar(l types.Logger) types.SugaredLogger {
+	if s, ok := l.(types.LogSugarFunc); ok {
+		return s.Sugar()
+	}
+	return NewSugaredLogger(l, AddCallerSkip(l, sugarCallerSkip))
+}
+
+func newSugaredLogger(base, logger types.Logger) *sugaredLogger {
+	return &sugaredLogger{
+		base:   base,
+		logger: logger,
+	}
+}
+
+func (s *sugaredLogger) Desugar() types.Logger {
+	return s.base
+}
+
+func (s *sugaredLogger) With(keysAndValues ...interface{}) types.SugaredLogger {
+	fields := sweetenFields(keysAndValues)
+	return newSugaredLogger(s.base.With(fields...), s.logger.With(fields...))
+}
+
+func (s *sugaredLogger) Debugf(template string, args ...interface{}) {
+	s.log(zapcore.DebugLevel, template, args, nil)
+}
+
+func (s *sugaredLogger) Infof(template string, args ...interface{}) {
+	s.log(zapcore.InfoLevel, template, args, nil)
+}
+
+func (s *sugaredLogger) Warnf(template string, args ...interface{}) {
+	s.log(zapcore.WarnLevel, template, args, nil)
+}
+
+func (s *sugaredLogger) Errorf(template string, args ...interface{}) {
+	s.log(zapcore.ErrorLevel, template, args, nil)
+}
+
+func (s *sugaredLogger) Panicf(template string, args ...interface{}) {
+	s.log(zapcore.PanicLevel, template, args, nil)
+}
+
+func (s *sugaredLogger) Fatalf(template string, args ...interface{}) {
+	s.log(zapcore.FatalLevel, template, args, nil)
+}
+
+func (s *sugaredLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	s.log(zapcore.DebugLevel, msg, nil, keysAndValues)
+}
+
+func (s *sugaredLogger) Infow(msg string, keysAndValues ...interface{}) {
+	s.log(zapcore.InfoLevel, msg, nil, keysAndValues)
+}
+
+func (s *sugaredLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	s.log(zapcore.WarnLevel, msg, nil, keysAndValues)
+}
+
+func (s *sugaredLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	s.log(zapcore.ErrorLevel, msg, nil, keysAndValues)
+}
+
+func (s *sugaredLogger) Panicw(msg string, keysAndValues ...interface{}) {
+	s.log(zapcore.PanicLevel, msg, nil, keysAndValues)
+}
+
+func (s *sugaredLogger) Fatalw(msg string, keysAndValues ...interface{}) {
+	s.log(zapcore.FatalLevel, msg, nil, keysAndValues)
+}
+
+// log must be called by the methods above directly, to keep the depth of sugarCallerSkip.
+func (s *sugaredLogger) log(level zapcore.Level, template string, args []interface{}, keysAndValues []interface{}) {
+	// panic and fatal logs are always written, as the logger panics or exits anyway
+	if level < zapcore.PanicLevel && !s.logger.Enabled(level) {
+		return
+	}
+
+	msg := template
+	if len(args) > 0 {
+		msg = fmt.Sprintf(template, args...)
+	}
+	fields := sweetenFields(keysAndValues)
+
+	switch level {
+	case zapcore.DebugLevel:
+		s.logger.Debug(msg, fields...)
+	case zapcore.InfoLevel:
+		s.logger.Info(msg, fields...)
+	case zapcore.WarnLevel:
+		s.logger.Warn(msg, fields...)
+	case zapcore.ErrorLevel:
+		s.logger.Error(msg, fields...)
+	case zapcore.PanicLevel:
+		s.logger.Panic(msg, fields...)
+	case zapcore.FatalLevel:
+		s.logger.Fatal(msg, fields...)
+	}
+}
+
+// Fields converts the key-value pairs into fields as the Infow-style API of SugaredLogger does.
+func Fields(keysAndValues ...interface{}) []zapcore.Field {
+	return sweetenFields(keysAndValues)
+}
+
+// sweetenFields converts the key-value pairs into fields.
+// A zapcore.Field is used as is, a non-string key is formatted, and a key without value is kept as `!BADKEY`.
+func sweetenFields(keysAndValues []interface{}) []zapcore.Field {
+	if len(keysAndValues) == 0 {
+		return nil
+	}
+	fields := make([]zapcore.Field, 0, len(keysAndValues)/2+1)
+	for i := 0; i < len(keysAndValues); {
+		if f, ok := keysAndValues[i].(zapcore.Field); ok {
+			fields = append(fields, f)
+			i++
+			continue
+		}
+		if i == len(keysAndValues)-1 {
+			fields = append(fields, zap.Any("!BADKEY", keysAndValues[i]))
+			break
+		}
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		fields = append(fields, zap.Any(key, keysAndValues[i+1]))
+		i += 2
+	}
+	return fields
+}
@@ -0,0 +1,57 @@
+package azap_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+
+	"go.uber.org/zap"
+)
+
+func TestSugaredLogger(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := azap.NewLogger(t.Name(),
+		options.WithWriter(buf),
+		options.WithVModule("sugar_test=2"),
+	)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	sugar := logger.Sugar()
+	sugar.Debugf("debug %s", "disabled")
+	sugar.Infof("user %s", "u1")
+	sugar.With("k", "v").Warnw("with pairs", "n", 1, zap.Bool("b", true), 2, "two", "odd")
+	azap.Sugar(logger.V(2)).Infof("v2 %d", 2)
+	azap.Sugar(logger.V(3)).Infof("v3 %d", 3)
+
+	ctx := context.WithValue(context.Background(), "request_id", "123456")
+	azap.Ctx(ctx, logger).Sugar().Errorw("with ctx")
+	azap.Sugar(azap.Ctx(ctx, logger).V(2)).Infof("ctx v2")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := [][]string{
+		{`"msg":"user u1"`},
+		{`"msg":"with pairs"`, `"k":"v"`, `"n":1`, `"b":true`, `"2":"two"`, `"!BADKEY":"odd"`},
+		{`"msg":"v2 2"`},
+		{`"msg":"with ctx"`, `"request_id":"123456"`},
+		{`"msg":"ctx v2"`, `"request_id":"123456"`},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %s", len(expected), buf.String())
+	}
+	for i, line := range lines {
+		for _, s := range append(expected[i], `"caller":"azap/sugar_test.go`) {
+			if !strings.Contains(line, s) {
+				t.Fatalf("`%s` is missing, got %s", s, line)
+			}
+		}
+	}
+	if sugar.Desugar() != logger {
+		t.Fatal("Desugar must return the wrapped logger")
+	}
+}
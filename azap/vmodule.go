@@ -0,0 +1,123 @@
+package azap
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// verboseState is the verbosity shared by a logger and all of its clones.
+type verboseState struct {
+	filter  atomic.Int32
+	vmodule atomic.Pointer[vmoduleRules]
+}
+
+func newVerboseState(filter int32) *verboseState {
+	state := &verboseState{}
+	state.filter.Store(filter)
+	state.vmodule.Store(&vmoduleRules{})
+	return state
+}
+
+// enabled reports whether logs of the verbose are printed. The caller's file is only resolved
+// if there are vmodule rules, skip is the number of frames above the caller of enabled.
+func (s *verboseState) enabled(verbose int32, skip int) bool {
+	if verbose <= s.filter.Load() {
+		return true
+	}
+	rules := s.vmodule.Load()
+	if len(rules.rules) == 0 {
+		return false
+	}
+	var pcs [1]uintptr
+	if runtime.Callers(skip+2, pcs[:]) == 0 {
+		return false
+	}
+	return verbose <= rules.lookup(pcs[0])
+}
+
+// enabledAt is enabled with the pc of the caller known, zero pc matches no vmodule rule.
+func (s *verboseState) enabledAt(verbose int32, pc uintptr) bool {
+	if verbose <= s.filter.Load() {
+		return true
+	}
+	rules := s.vmodule.Load()
+	if len(rules.rules) == 0 || pc == 0 {
+		return false
+	}
+	return verbose <= rules.lookup(pc)
+}
+
+func (s *verboseState) reload(spec string) error {
+	rules, err := parseVModuleRules(spec)
+	if err != nil {
+		return err
+	}
+	s.vmodule.Store(rules)
+	return nil
+}
+
+type vmoduleRule struct {
+	pattern string
+	verbose int32
+}
+
+// vmoduleRules is an immutable set of vmodule rules, the lookups are cached by program counter.
+type vmoduleRules struct {
+	spec  string
+	rules []vmoduleRule
+	cache sync.Map // pc -> int32
+}
+
+// parseVModuleRules parses glog-style rules like `gopher*=3,server=2`.
+//
+// A pattern is matched against the caller's file name without the `.go` suffix,
+// or against the full path if it contains `/`. The first matching rule wins.
+func parseVModuleRules(spec string) (*vmoduleRules, error) {
+	rules := &vmoduleRules{spec: strings.TrimSpace(spec)}
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		pattern, text, ok := strings.Cut(item, "=")
+		if pattern = strings.TrimSpace(pattern); !ok || pattern == "" {
+			return nil, fmt.Errorf("bad vmodule rule `%s`, expect pattern=N", item)
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad vmodule rule `%s`, %w", item, err)
+		}
+		verbose, err := strconv.ParseInt(strings.TrimSpace(text), 10, 32)
+		if err != nil || verbose < 0 {
+			return nil, fmt.Errorf("bad vmodule rule `%s`, verbose must be a non-negative integer", item)
+		}
+		rules.rules = append(rules.rules, vmoduleRule{pattern: pattern, verbose: int32(verbose)})
+	}
+	return rules, nil
+}
+
+// lookup returns the verbose of the caller at pc, or -1 if no rule matches its file.
+func (r *vmoduleRules) lookup(pc uintptr) int32 {
+	if v, ok := r.cache.Load(pc); ok {
+		return v.(int32)
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	file := strings.TrimSuffix(frame.File, ".go")
+	verbose := int32(-1)
+	for _, rule := range r.rules {
+		name := file
+		if !strings.Contains(rule.pattern, "/") {
+			name = filepath.Base(file)
+		}
+		if ok, _ := filepath.Match(rule.pattern, name); ok {
+			verbose = rule.verbose
+			break
+		}
+	}
+	r.cache.Store(pc, verbose)
+	return verbose
+}
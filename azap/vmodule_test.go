@@ -0,0 +1,78 @@
+package azap_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestZapLogger_VerbosePropagation(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := azap.NewLogger(t.Name(), options.WithWriter(buf))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	named := logger.Named("child")
+	verbose := named.V(2)
+	verbose.Info("v2-before")
+
+	if err := logger.HostReloadLogVerbose(2); err != nil {
+		t.Fatalf("reload verbose failed, %v", err)
+	}
+	verbose.Info("v2-after")
+	named.V(2).Info("v2-new")
+
+	if strings.Contains(buf.String(), "v2-before") ||
+		!strings.Contains(buf.String(), "v2-after") ||
+		!strings.Contains(buf.String(), "v2-new") {
+		t.Fatalf("verbose not shared with derived loggers, got %s", buf.String())
+	}
+	if !verbose.Enabled(zapcore.InfoLevel) || named.V(3).Enabled(zapcore.InfoLevel) {
+		t.Fatal("Enabled does not follow the verbose")
+	}
+}
+
+func TestZapLogger_VModule(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := azap.NewLogger(t.Name(),
+		options.WithWriter(buf),
+		options.WithVerboseFilter(1),
+		options.WithVModule("other=5,vmodule_*=3"),
+	)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	logger.V(3).Info("v3-matched")
+	logger.V(4).Info("v4-matched")
+	if !strings.Contains(buf.String(), "v3-matched") || strings.Contains(buf.String(), "v4-matched") {
+		t.Fatalf("vmodule not work, got %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "vmodule_test.go") {
+		t.Fatalf("bad caller skip settings, got %s", buf.String())
+	}
+
+	// the verbose still applies if it is higher than the vmodule
+	buf.Reset()
+	if err := logger.HotReloadLogVModule("*/azap/vmodule_test=0"); err != nil {
+		t.Fatalf("reload vmodule failed, %v", err)
+	}
+	logger.V(1).Info("v1-verbose")
+	logger.V(2).Info("v2-unmatched")
+	if !strings.Contains(buf.String(), "v1-verbose") || strings.Contains(buf.String(), "v2-unmatched") {
+		t.Fatalf("reloaded vmodule not work, got %s", buf.String())
+	}
+
+	if err := logger.HotReloadLogVModule("vmodule_test=-1"); err == nil {
+		t.Fatal("bad rules must be rejected")
+	}
+	if _, err := azap.NewLogger(t.Name(), options.WithVModule("[=1")); err == nil {
+		t.Fatal("bad rules must be rejected")
+	}
+}
@@ -0,0 +1,146 @@
+package azap
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// maxLineSize is the max size of a pending line, a longer line is logged in pieces.
+const maxLineSize = 64 * 1024
+
+var linePrefixLevels = map[byte]zapcore.Level{
+	'D': zapcore.DebugLevel,
+	'I': zapcore.InfoLevel,
+	'W': zapcore.WarnLevel,
+	'E': zapcore.ErrorLevel,
+}
+
+// LineWriter is an io.Writer logging each line written to it as an entry.
+// Close logs the last line without a line break.
+type LineWriter struct {
+	logger      types.Logger
+	level       zapcore.Level
+	levelPrefix bool
+	callerSkip  int
+
+	mutex sync.Mutex
+	buf   []byte
+}
+
+type LineWriterOption func(w *LineWriter)
+
+// WithLineLevelPrefix recognizes the level prefixes [D], [I], [W] and [E] used by the internal loggers of writers,
+// the prefix may follow the date and time of the standard logger. Lines with a prefix are logged at its level.
+func WithLineLevelPrefix() LineWriterOption {
+	return func(w *LineWriter) {
+		w.levelPrefix = true
+	}
+}
+
+// WithLineCallerSkip reports the caller skip frames above Write, e.g. 3 for the standard log package.
+// By default, the caller is omitted as it is meaningless for a writer, e.g. stdout of a subprocess.
+func WithLineCallerSkip(skip int) LineWriterOption {
+	return func(w *LineWriter) {
+		if skip > 0 {
+			w.callerSkip = skip
+		}
+	}
+}
+
+// Writer returns the line writer of l by types.LogWriterFunc if l implements it, otherwise a LineWriter of l.
+func Writer(l types.Logger, level zapcore.Level) io.WriteCloser {
+	if w, ok := l.(types.LogWriterFunc); ok {
+		return w.Writer(level)
+	}
+	return NewLineWriter(l, level)
+}
+
+// NewLineWriter new a LineWriter logging the lines by logger at level.
+func NewLineWriter(logger types.Logger, level zapcore.Level, opts ...LineWriterOption) *LineWriter {
+	w := &LineWriter{
+		logger: logger,
+		level:  level,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	var caller zapcore.EntryCaller
+	if w.callerSkip > 0 {
+		var pcs [1]uintptr
+		if runtime.Callers(w.callerSkip+1, pcs[:]) > 0 {
+			frame, _ := runtime.CallersFrames(pcs[:]).Next()
+			caller = zapcore.NewEntryCaller(pcs[0], frame.File, frame.Line, true)
+		}
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	data := append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		w.log(data[:i], caller)
+		data = data[i+1:]
+	}
+	for len(data) >= maxLineSize {
+		w.log(data[:maxLineSize], caller)
+		data = data[maxLineSize:]
+	}
+	w.buf = append(w.buf[:0], data...)
+	return len(p), nil
+}
+
+func (w *LineWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if len(w.buf) > 0 {
+		w.log(w.buf, zapcore.EntryCaller{})
+		w.buf = w.buf[:0]
+	}
+	return nil
+}
+
+func (w *LineWriter) log(line []byte, caller zapcore.EntryCaller) {
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	level := w.level
+	if w.levelPrefix {
+		level, line = w.parseLevelPrefix(line)
+	}
+	if len(line) == 0 {
+		return
+	}
+	LogEntry(w.logger, zapcore.Entry{
+		Level:   level,
+		Time:    time.Now(),
+		Message: string(line),
+		Caller:  caller,
+	})
+}
+
+// parseLevelPrefix returns the level of the prefix and the line without the date, time and prefix.
+func (w *LineWriter) parseLevelPrefix(line []byte) (zapcore.Level, []byte) {
+	rest := bytes.TrimLeft(line, "0123456789/:. ")
+	if len(rest) < 4 || rest[0] != '[' || rest[2] != ']' || rest[3] != ' ' {
+		return w.level, line
+	}
+	level, ok := linePrefixLevels[rest[1]]
+	if !ok {
+		return w.level, line
+	}
+	return level, bytes.TrimSpace(rest[4:])
+}
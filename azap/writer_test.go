@@ -0,0 +1,55 @@
+package azap_test
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLineWriter(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := azap.NewLogger(t.Name(), options.WithWriter(buf), options.WithLogLevel(zapcore.DebugLevel))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	w := logger.Writer(zapcore.WarnLevel)
+	_, _ = w.Write([]byte("first line\nsecond "))
+	_, _ = w.Write([]byte("line\r\n\n[E] not a prefix\nlast"))
+	if strings.Contains(buf.String(), "last") {
+		t.Fatalf("a line must not be logged before its line break, got %s", buf.String())
+	}
+	_ = w.Close()
+
+	prefixed := azap.NewLineWriter(logger, zapcore.InfoLevel, azap.WithLineLevelPrefix())
+	_, _ = prefixed.Write([]byte("2024/01/02 03:04:05 [E] open file failed\n[D] debug\n[X] unknown\nplain\n"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		`"level":"WARN".*"msg":"first line"`,
+		`"level":"WARN".*"msg":"second line"`,
+		`"level":"WARN".*"msg":"\[E\] not a prefix"`,
+		`"level":"WARN".*"msg":"last"`,
+		`"level":"ERROR".*"msg":"open file failed"`,
+		`"level":"DEBUG".*"msg":"debug"`,
+		`"level":"INFO".*"msg":"\[X\] unknown"`,
+		`"level":"INFO".*"msg":"plain"`,
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %s", len(expected), buf.String())
+	}
+	for i, line := range lines {
+		if !regexp.MustCompile(expected[i]).MatchString(line) {
+			t.Fatalf("line %d mismatch, expected %s, got %s", i, expected[i], line)
+		}
+		if strings.Contains(line, `"caller"`) {
+			t.Fatalf("caller must be omitted, got %s", line)
+		}
+	}
+}
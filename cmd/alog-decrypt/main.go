@@ -0,0 +1,156 @@
+// Command alog-decrypt decrypts log segments written by writers.FileWriter in
+// encrypting segment mode and streams the plain entries to stdout.
+//
+// Usage:
+//
+//	alog-decrypt -key <id>=<hex key> [-key <id>=<hex key>...] <segment file or dir>...
+//	alog-decrypt -key-file keys.txt <segment file or dir>...
+//
+// The key file holds one `<id>=<hex key>` per line. Segments in a directory are
+// decrypted in filename order.
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/csh0101/alog/writers"
+)
+
+type keyFlags map[string][]byte
+
+func (f keyFlags) String() string {
+	ids := make([]string, 0, len(f))
+	for id := range f {
+		ids = append(ids, id)
+	}
+	return strings.Join(ids, ",")
+}
+
+func (f keyFlags) Set(v string) error {
+	id, hexKey, ok := strings.Cut(strings.TrimSpace(v), "=")
+	if !ok || id == "" {
+		return fmt.Errorf("bad key `%s`, expected <id>=<hex key>", v)
+	}
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return fmt.Errorf("decode key `%s` failed, %w", id, err)
+	}
+	f[id] = key
+	return nil
+}
+
+func main() {
+	keys := keyFlags{}
+	flag.Var(keys, "key", "master key as `<id>=<hex key>`, can be repeated")
+	keyFile := flag.String("key-file", "", "file holding one `<id>=<hex key>` per line")
+	flag.Parse()
+
+	if err := run(keys, *keyFile, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "alog-decrypt: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(keys keyFlags, keyFile string, paths []string) error {
+	if keyFile != "" {
+		if err := loadKeyFile(keys, keyFile); err != nil {
+			return err
+		}
+	}
+	if len(keys) == 0 {
+		return errors.New("at least one key is required")
+	}
+	if len(paths) == 0 {
+		return errors.New("at least one segment file or dir is required")
+	}
+
+	var currentID string
+	for id := range keys {
+		currentID = id
+		break
+	}
+	kp, err := writers.NewStaticKeyProvider(currentID, keys)
+	if err != nil {
+		return err
+	}
+
+	files, err := segmentFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	for _, file := range files {
+		if err := decryptFile(out, file, kp); err != nil {
+			return fmt.Errorf("decrypt `%s` failed, %w", file, err)
+		}
+	}
+	return nil
+}
+
+func decryptFile(out *bufio.Writer, file string, kp writers.KeyProvider) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return writers.DecryptSegment(out, f, kp)
+}
+
+func loadKeyFile(keys keyFlags, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open key file failed, %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := keys.Set(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func segmentFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		dirFiles := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				dirFiles = append(dirFiles, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(dirFiles)
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
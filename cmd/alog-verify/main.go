@@ -0,0 +1,65 @@
+// Command alog-verify verifies the hash-chained log segments written by
+// writers.FileWriter in tamper-evident segment mode and reports the first broken link.
+//
+// Usage:
+//
+//	alog-verify [-prefix <file prefix>] [-ext <file ext>] [-key-file <file>] [-start <hash>] [-head <hash>] <dir>
+//
+// -key-file verifies a chain keyed by writers.WithHashChainKey, the trailing whitespace of the
+// file, e.g. the line break added by editors, is not a part of the key. -start and -head require
+// the chain to start from and reach the given hex hashes, to detect deleted leading segments and
+// a truncated tail.
+//
+// It exits with status 1 if the chain is broken and 2 if the segments can't be read.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/csh0101/alog/writers"
+)
+
+func main() {
+	prefix := flag.String("prefix", "", "only verify segments with the file prefix")
+	ext := flag.String("ext", "", "only verify segments with the file ext")
+	keyFile := flag.String("key-file", "", "the file holding the key of a keyed chain")
+	start := flag.String("start", "", "the hex hash the first segment must chain to")
+	head := flag.String("head", "", "the hex hash the chain must reach")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: alog-verify [-prefix <file prefix>] [-ext <file ext>] [-key-file <file>] [-start <hash>] [-head <hash>] <dir>")
+		os.Exit(2)
+	}
+
+	var opts []writers.ChainVerifyOption
+	if *keyFile != "" {
+		key, err := os.ReadFile(*keyFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "alog-verify: read key failed, %v\n", err)
+			os.Exit(2)
+		}
+		opts = append(opts, writers.WithChainKey(bytes.TrimRight(key, " \t\r\n")))
+	}
+	if *start != "" {
+		opts = append(opts, writers.WithChainStart(*start))
+	}
+	if *head != "" {
+		opts = append(opts, writers.WithChainHead(*head))
+	}
+
+	report, err := writers.VerifyHashChainDir(flag.Arg(0), *prefix, *ext, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alog-verify: %v\n", err)
+		os.Exit(2)
+	}
+	if report.Break != nil {
+		fmt.Printf("BROKEN: %v\n", report.Break)
+		fmt.Printf("verified %d entries in %d segments before the break\n", report.Records, report.Files)
+		os.Exit(1)
+	}
+	fmt.Printf("OK: verified %d entries in %d segments, head %s\n", report.Records, report.Files, report.Head)
+}
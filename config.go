@@ -0,0 +1,675 @@
+package alog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+	"github.com/csh0101/alog/types"
+	"github.com/csh0101/alog/writers"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes a logger, it can be decoded from YAML or JSON, see LoadConfig.
+//
+//	level: info
+//	levels: svc.db.*=debug
+//	verbose: 1
+//	encoding: json
+//	writers:
+//	  - type: stderr
+//	    level: warn
+//	  - type: file
+//	    file: {dir: /var/log/svc, prefix: svc, ext: .log, retention: 72h}
+//	  - type: tcp
+//	    address: collector:5170
+//	    level: error
+type Config struct {
+	// Level is the log level, default is info.
+	Level string `json:"level" yaml:"level"`
+	// Levels are the per-module log levels, see options.WithLogLevels.
+	Levels string `json:"levels" yaml:"levels"`
+	// Verbose is the verbose filter, see options.WithVerboseFilter.
+	Verbose int `json:"verbose" yaml:"verbose"`
+	// VModule are the per-file verbose rules, see options.WithVModule.
+	VModule string `json:"vmodule" yaml:"vmodule"`
+	// Encoding is json or console, default is json.
+	Encoding string `json:"encoding" yaml:"encoding"`
+	// DisableCaller can not be reloaded.
+	DisableCaller bool `json:"disableCaller" yaml:"disableCaller"`
+	// StacktraceLevel is the level to capture stacktrace from, default is fatal. It can not be reloaded.
+	StacktraceLevel string `json:"stacktraceLevel" yaml:"stacktraceLevel"`
+	// RichErrors encodes the causes and stacks of errors, see options.WithRichErrors. It can not be reloaded.
+	RichErrors bool          `json:"richErrors" yaml:"richErrors"`
+	Encoder    EncoderConfig `json:"encoder" yaml:"encoder"`
+	// Writers default to stderr.
+	Writers []WriterConfig `json:"writers" yaml:"writers"`
+	// WatchInterval is how often NewLoggerFromConfigFile checks the file for changes, default is 1s.
+	WatchInterval Duration `json:"watchInterval" yaml:"watchInterval"`
+}
+
+// EncoderConfig overrides the default encoder config of azap.NewEncoderConfig.
+// Empty fields are left as default, and a key set to `-` is omitted from the output.
+type EncoderConfig struct {
+	MessageKey    string `json:"messageKey" yaml:"messageKey"`
+	LevelKey      string `json:"levelKey" yaml:"levelKey"`
+	TimeKey       string `json:"timeKey" yaml:"timeKey"`
+	NameKey       string `json:"nameKey" yaml:"nameKey"`
+	CallerKey     string `json:"callerKey" yaml:"callerKey"`
+	StacktraceKey string `json:"stacktraceKey" yaml:"stacktraceKey"`
+	// TimeEncoder is one of rfc3339nano, rfc3339, iso8601, millis, nanos or epoch.
+	TimeEncoder string `json:"timeEncoder" yaml:"timeEncoder"`
+	// LevelEncoder is one of capital, capitalColor, color or lowercase.
+	LevelEncoder string `json:"levelEncoder" yaml:"levelEncoder"`
+	// DurationEncoder is one of string, nanos, ms or seconds.
+	DurationEncoder string `json:"durationEncoder" yaml:"durationEncoder"`
+	// CallerEncoder is one of full or short.
+	CallerEncoder string `json:"callerEncoder" yaml:"callerEncoder"`
+}
+
+// WriterConfig describes a writer.
+type WriterConfig struct {
+	// Type is one of stderr, stdout, file, or a network of writers.NewNetWriter, e.g. tcp.
+	Type string `json:"type" yaml:"type"`
+	// Level is the minimum level written to the writer, after the log level of the logger.
+	Level string `json:"level" yaml:"level"`
+	// Encoding overrides the encoding of the logger.
+	Encoding string `json:"encoding" yaml:"encoding"`
+	// Address is the address of network writers.
+	Address      string      `json:"address" yaml:"address"`
+	DialTimeout  Duration    `json:"dialTimeout" yaml:"dialTimeout"`
+	WriteTimeout Duration    `json:"writeTimeout" yaml:"writeTimeout"`
+	File         *FileConfig `json:"file" yaml:"file"`
+}
+
+// FileConfig describes a writers.FileWriter.
+type FileConfig struct {
+	Dir             string            `json:"dir" yaml:"dir"`
+	Prefix          string            `json:"prefix" yaml:"prefix"`
+	Ext             string            `json:"ext" yaml:"ext"`
+	MaxSizeInBytes  int64             `json:"maxSizeInBytes" yaml:"maxSizeInBytes"`
+	Retention       Duration          `json:"retention" yaml:"retention"`
+	TotalCountLimit int               `json:"totalCountLimit" yaml:"totalCountLimit"`
+	FileMode        FileMode          `json:"fileMode" yaml:"fileMode"`
+	DirMode         FileMode          `json:"dirMode" yaml:"dirMode"`
+	UID             *int              `json:"uid" yaml:"uid"`
+	GID             *int              `json:"gid" yaml:"gid"`
+	HashChain       bool              `json:"hashChain" yaml:"hashChain"`
+	Encryption      *EncryptionConfig `json:"encryption" yaml:"encryption"`
+	S3              *S3Config         `json:"s3" yaml:"s3"`
+}
+
+// EncryptionConfig describes a writers.StaticKeyProvider.
+type EncryptionConfig struct {
+	CurrentKey string `json:"currentKey" yaml:"currentKey"`
+	// Keys are hex encoded AES keys by id.
+	Keys map[string]string `json:"keys" yaml:"keys"`
+}
+
+// S3Config describes a writers.S3Archiver.
+type S3Config struct {
+	Endpoint        string `json:"endpoint" yaml:"endpoint"`
+	Bucket          string `json:"bucket" yaml:"bucket"`
+	Region          string `json:"region" yaml:"region"`
+	AccessKeyID     string `json:"accessKeyId" yaml:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey" yaml:"secretAccessKey"`
+	SessionToken    string `json:"sessionToken" yaml:"sessionToken"`
+	PathStyle       *bool  `json:"pathStyle" yaml:"pathStyle"`
+	KeyTemplate     string `json:"keyTemplate" yaml:"keyTemplate"`
+	Service         string `json:"service" yaml:"service"`
+	Host            string `json:"host" yaml:"host"`
+	PartSize        int64  `json:"partSize" yaml:"partSize"`
+}
+
+// Duration is a time.Duration decoded from a string like `72h`.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	return d.UnmarshalText([]byte(node.Value))
+}
+
+// FileMode is an os.FileMode decoded from an octal string like `0640`.
+type FileMode os.FileMode
+
+func (m *FileMode) UnmarshalText(b []byte) error {
+	v, err := strconv.ParseUint(string(b), 8, 32)
+	if err != nil {
+		return fmt.Errorf("bad file mode `%s`, expect octal like 0640", b)
+	}
+	*m = FileMode(v)
+	return nil
+}
+
+func (m FileMode) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%#o", uint32(m))), nil
+}
+
+// UnmarshalYAML decodes the raw text, as YAML may take `0640` for a decimal.
+func (m *FileMode) UnmarshalYAML(node *yaml.Node) error {
+	return m.UnmarshalText([]byte(node.Value))
+}
+
+// LoadConfig decodes the config file, as JSON if its extension is `.json`, otherwise as YAML.
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config failed, %w", err)
+	}
+	return decodeConfig(path, b)
+}
+
+func decodeConfig(path string, b []byte) (*Config, error) {
+	cfg := &Config{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("decode config `%s` failed, %w", path, err)
+		}
+		return cfg, nil
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("decode config `%s` failed, %w", path, err)
+	}
+	return cfg, nil
+}
+
+// NewLoggerFromConfig new a logger described by cfg. The options are applied after the config.
+// Close of the logger closes the writers it opened.
+func NewLoggerFromConfig(loggerName string, cfg *Config, opts ...options.LoggerOption) (types.Logger, error) {
+	return newConfigLogger(loggerName, cfg, opts)
+}
+
+// NewLoggerFromConfigFile new a logger described by the config file, see LoadConfig.
+// The file is watched, and the level, levels, verbose, vmodule, encoder and writers are
+// reloaded once it changes. Entries being written during the reload go to the old writers,
+// which are flushed and closed after. A bad config is reported by the logger and ignored.
+func NewLoggerFromConfigFile(loggerName, path string, opts ...options.LoggerOption) (types.Logger, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config failed, %w", err)
+	}
+	cfg, err := decodeConfig(path, b)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := newConfigLogger(loggerName, cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(cfg.WatchInterval)
+	if interval <= 0 {
+		interval = time.Second
+	}
+	logger.done = make(chan struct{})
+	logger.wg.Add(1)
+	go logger.watch(path, b, interval)
+	return logger, nil
+}
+
+type configLogger struct {
+	azap.ZapLogger
+
+	sinks   *azap.Sinks
+	mutex   sync.Mutex
+	writers map[string]*configWriter
+
+	once sync.Once
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// configWriter is a writer opened for a WriterConfig, it is reused by the configs having the same key.
+// File writers are keyed by their dir, as two of them must never write the same dir.
+type configWriter struct {
+	zapcore.WriteSyncer
+	closer io.Closer
+	// config is the WriterConfig it was opened for, without the level and encoding
+	config string
+}
+
+func newConfigLogger(loggerName string, cfg *Config, opts []options.LoggerOption) (*configLogger, error) {
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	stacktrace := zapcore.FatalLevel
+	if cfg.StacktraceLevel != "" {
+		if stacktrace, err = parseLevel(cfg.StacktraceLevel); err != nil {
+			return nil, err
+		}
+	}
+
+	logger := &configLogger{writers: make(map[string]*configWriter)}
+	sinks, writers, err := logger.buildSinks(cfg)
+	if err != nil {
+		return nil, err
+	}
+	logger.writers = writers
+	logger.sinks = azap.NewSinks(sinks...)
+	opts = append([]options.LoggerOption{
+		options.WithCore(logger.sinks.Core()),
+		options.WithLogLevel(level),
+		options.WithLogLevels(cfg.Levels),
+		options.WithVerboseFilter(cfg.Verbose),
+		options.WithVModule(cfg.VModule),
+		options.WithDisableCaller(cfg.DisableCaller),
+		options.WithStacktraceLevel(stacktrace),
+		options.WithRichErrors(cfg.RichErrors),
+	}, opts...)
+	if logger.ZapLogger, err = azap.NewLogger(loggerName, opts...); err != nil {
+		_ = closeWriters(writers)
+		return nil, err
+	}
+	return logger, nil
+}
+
+// Close stops watching the config file, then flushes and closes the writers.
+func (l *configLogger) Close() error {
+	var err error
+	l.once.Do(func() {
+		if l.done != nil {
+			close(l.done)
+			l.wg.Wait()
+		}
+
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+
+		err = l.ZapLogger.Close()
+		l.sinks.Swap()
+		err = multierr.Append(err, closeWriters(l.writers))
+		l.writers = nil
+	})
+	return err
+}
+
+func (l *configLogger) watch(path string, last []byte, interval time.Duration) {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			// the file may be being replaced, e.g. by an editor or a ConfigMap update
+			continue
+		}
+		if bytes.Equal(b, last) {
+			continue
+		}
+		last = b
+		if err := l.reload(path, b); err != nil {
+			l.Error("reload config failed", zap.String("path", path), zap.Error(err))
+		} else {
+			l.Info("config reloaded", zap.String("path", path))
+		}
+	}
+}
+
+func (l *configLogger) reload(path string, b []byte) error {
+	cfg, err := decodeConfig(path, b)
+	if err != nil {
+		return err
+	}
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.writers == nil {
+		return errors.New("logger is closed")
+	}
+	sinks, writers, err := l.buildSinks(cfg)
+	if err != nil {
+		return err
+	}
+	kept := keptFileWriters(cfg, l.writers)
+	before := l.LogLevels()
+	if err := l.HotReloadLogLevels(cfg.Levels); err != nil {
+		_ = closeUnused(writers, l.writers)
+		return err
+	}
+	if err := l.HotReloadLogVModule(cfg.VModule); err != nil {
+		_ = l.HotReloadLogLevels(before)
+		_ = closeUnused(writers, l.writers)
+		return err
+	}
+	_ = l.HotReloadLogLevel(level)
+	_ = l.HostReloadLogVerbose(cfg.Verbose)
+
+	// entries in flight are finished before Swap returns
+	l.sinks.Swap(sinks...)
+	err = closeUnused(l.writers, writers)
+	l.writers = writers
+	if err != nil {
+		return fmt.Errorf("close replaced writers failed, %w", err)
+	}
+	return kept
+}
+
+// keptFileWriters reports the running file writers reused for configs with other options,
+// the options can't be applied without reopening the dir and so need a restart.
+func keptFileWriters(cfg *Config, running map[string]*configWriter) error {
+	var err error
+	for i, wc := range cfg.Writers {
+		key, config, e := writerKey(wc)
+		if e != nil || wc.Type != "file" {
+			continue
+		}
+		if w, ok := running[key]; ok && w.config != config {
+			err = multierr.Append(err, fmt.Errorf("writers[%d]: options of the file writer in `%s` are not reloaded, restart to apply them", i, wc.File.Dir))
+		}
+	}
+	return err
+}
+
+// closeUnused syncs and closes the writers of from that are not in to.
+func closeUnused(from, to map[string]*configWriter) error {
+	unused := make(map[string]*configWriter)
+	for key, w := range from {
+		if _, ok := to[key]; !ok {
+			unused[key] = w
+		}
+	}
+	return closeWriters(unused)
+}
+
+func closeWriters(writers map[string]*configWriter) error {
+	var err error
+	for _, w := range writers {
+		err = multierr.Append(err, w.Sync())
+		if w.closer != nil {
+			err = multierr.Append(err, w.closer.Close())
+		}
+	}
+	return err
+}
+
+// buildSinks must be called with the lock held, writers of the same config are reused.
+func (l *configLogger) buildSinks(cfg *Config) ([]azap.Sink, map[string]*configWriter, error) {
+	encoders := make(map[string]zapcore.Encoder)
+	writers := make(map[string]*configWriter)
+	fail := func(err error) ([]azap.Sink, map[string]*configWriter, error) {
+		_ = closeUnused(writers, l.writers)
+		return nil, nil, err
+	}
+
+	configs := cfg.Writers
+	if len(configs) == 0 {
+		configs = []WriterConfig{{Type: "stderr"}}
+	}
+	sinks := make([]azap.Sink, 0, len(configs))
+	for i, wc := range configs {
+		encoding := wc.Encoding
+		if encoding == "" {
+			encoding = cfg.Encoding
+		}
+		encoder, ok := encoders[encoding]
+		if !ok {
+			var err error
+			if encoder, err = newEncoder(encoding, cfg.Encoder); err != nil {
+				return fail(err)
+			}
+			encoders[encoding] = encoder
+		}
+
+		var level zapcore.LevelEnabler
+		if wc.Level != "" {
+			v, err := parseLevel(wc.Level)
+			if err != nil {
+				return fail(fmt.Errorf("writers[%d]: %w", i, err))
+			}
+			level = v
+		}
+
+		key, config, err := writerKey(wc)
+		if err != nil {
+			return fail(fmt.Errorf("writers[%d]: %w", i, err))
+		}
+		w, ok := writers[key]
+		if ok && w.config != config {
+			return fail(fmt.Errorf("writers[%d]: dir `%s` is used by another file writer", i, wc.File.Dir))
+		}
+		if !ok {
+			if w, ok = l.writers[key]; !ok {
+				if w, err = newConfigWriter(wc); err != nil {
+					return fail(fmt.Errorf("writers[%d]: %w", i, err))
+				}
+				w.config = config
+			}
+			writers[key] = w
+		}
+		sinks = append(sinks, azap.Sink{Writer: w, Encoder: encoder, Level: level})
+	}
+	return sinks, writers, nil
+}
+
+// writerKey tells if two configs open the same writer, the level and encoding are not a part of it.
+// A file writer is keyed by its dir, and config tells if the other options are the same.
+func writerKey(wc WriterConfig) (key, config string, err error) {
+	wc.Level, wc.Encoding = "", ""
+	b, err := json.Marshal(wc)
+	if err != nil {
+		return "", "", fmt.Errorf("marshal writer config failed, %w", err)
+	}
+	if wc.Type == "file" && wc.File != nil {
+		return "file:" + filepath.Clean(wc.File.Dir), string(b), nil
+	}
+	return string(b), string(b), nil
+}
+
+func newConfigWriter(wc WriterConfig) (*configWriter, error) {
+	switch wc.Type {
+	case "stderr":
+		return &configWriter{WriteSyncer: zapcore.Lock(os.Stderr)}, nil
+	case "stdout":
+		return &configWriter{WriteSyncer: zapcore.Lock(os.Stdout)}, nil
+	case "file":
+		w, err := newFileWriter(wc.File)
+		if err != nil {
+			return nil, err
+		}
+		return &configWriter{WriteSyncer: zapcore.AddSync(w), closer: w}, nil
+	default:
+		w, err := writers.NewNetWriter(wc.Type, wc.Address,
+			writers.WithNetDialTimeout(time.Duration(wc.DialTimeout)),
+			writers.WithNetWriteTimeout(time.Duration(wc.WriteTimeout)),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("new writer of type `%s` failed, %w", wc.Type, err)
+		}
+		return &configWriter{WriteSyncer: zapcore.AddSync(w), closer: w}, nil
+	}
+}
+
+// fileWriter closes the archiver of the FileWriter it opened.
+type fileWriter struct {
+	*writers.FileWriter
+	archiver *writers.S3Archiver
+}
+
+func (w *fileWriter) Close() error {
+	err := w.FileWriter.Close()
+	if w.archiver != nil {
+		err = multierr.Append(err, w.archiver.Close())
+	}
+	return err
+}
+
+func newFileWriter(fc *FileConfig) (*fileWriter, error) {
+	if fc == nil {
+		return nil, errors.New("file is required for writers of type file")
+	}
+
+	opts := []writers.FileWriterOption{
+		writers.WithFilePrefix(fc.Prefix),
+		writers.WithFileExt(fc.Ext),
+		writers.WithFileMaxSizeInBytes(fc.MaxSizeInBytes),
+		writers.WithFileTotalCountLimit(fc.TotalCountLimit),
+		writers.WithFileMode(os.FileMode(fc.FileMode)),
+		writers.WithDirMode(os.FileMode(fc.DirMode)),
+	}
+	if fc.Retention > 0 {
+		opts = append(opts, writers.WithFileRetention(time.Duration(fc.Retention)))
+	}
+	if fc.UID != nil || fc.GID != nil {
+		uid, gid := -1, -1
+		if fc.UID != nil {
+			uid = *fc.UID
+		}
+		if fc.GID != nil {
+			gid = *fc.GID
+		}
+		opts = append(opts, writers.WithFileOwner(uid, gid))
+	}
+	if fc.HashChain {
+		opts = append(opts, writers.WithHashChain())
+	}
+	if ec := fc.Encryption; ec != nil {
+		keys := make(map[string][]byte, len(ec.Keys))
+		for id, v := range ec.Keys {
+			key, err := hex.DecodeString(v)
+			if err != nil {
+				return nil, fmt.Errorf("bad encryption key `%s`, %w", id, err)
+			}
+			keys[id] = key
+		}
+		kp, err := writers.NewStaticKeyProvider(ec.CurrentKey, keys)
+		if err != nil {
+			return nil, fmt.Errorf("new key provider failed, %w", err)
+		}
+		opts = append(opts, writers.WithEncryption(kp))
+	}
+
+	var archiver *writers.S3Archiver
+	if sc := fc.S3; sc != nil {
+		s3Opts := []writers.S3ArchiverOption{
+			writers.WithS3Credentials(sc.AccessKeyID, sc.SecretAccessKey, sc.SessionToken),
+			writers.WithS3Region(sc.Region),
+			writers.WithS3KeyTemplate(sc.KeyTemplate),
+			writers.WithS3Service(sc.Service),
+			writers.WithS3Host(sc.Host),
+			writers.WithS3PartSize(sc.PartSize),
+		}
+		if sc.PathStyle != nil {
+			s3Opts = append(s3Opts, writers.WithS3PathStyle(*sc.PathStyle))
+		}
+		var err error
+		if archiver, err = writers.NewS3Archiver(sc.Endpoint, sc.Bucket, s3Opts...); err != nil {
+			return nil, fmt.Errorf("new s3 archiver failed, %w", err)
+		}
+		opts = append(opts, writers.WithArchiver(archiver))
+	}
+
+	w, err := writers.NewFileWriter(fc.Dir, opts...)
+	if err != nil {
+		if archiver != nil {
+			archiver.Close()
+		}
+		return nil, fmt.Errorf("new file writer failed, %w", err)
+	}
+	return &fileWriter{FileWriter: w, archiver: archiver}, nil
+}
+
+func newEncoder(encoding string, ec EncoderConfig) (zapcore.Encoder, error) {
+	cfg := azap.NewEncoderConfig()
+	for _, key := range []struct {
+		dst *string
+		v   string
+	}{
+		{&cfg.MessageKey, ec.MessageKey},
+		{&cfg.LevelKey, ec.LevelKey},
+		{&cfg.TimeKey, ec.TimeKey},
+		{&cfg.NameKey, ec.NameKey},
+		{&cfg.CallerKey, ec.CallerKey},
+		{&cfg.StacktraceKey, ec.StacktraceKey},
+	} {
+		switch key.v {
+		case "":
+		case "-":
+			*key.dst = zapcore.OmitKey
+		default:
+			*key.dst = key.v
+		}
+	}
+	for _, enc := range []struct {
+		dst  interface{ UnmarshalText([]byte) error }
+		v    string
+		name string
+	}{
+		{&cfg.EncodeTime, ec.TimeEncoder, "time"},
+		{&cfg.EncodeLevel, ec.LevelEncoder, "level"},
+		{&cfg.EncodeDuration, ec.DurationEncoder, "duration"},
+		{&cfg.EncodeCaller, ec.CallerEncoder, "caller"},
+	} {
+		if enc.v == "" {
+			continue
+		}
+		if err := enc.dst.UnmarshalText([]byte(enc.v)); err != nil {
+			return nil, fmt.Errorf("bad %s encoder `%s`, %w", enc.name, enc.v, err)
+		}
+	}
+
+	switch encoding {
+	case "", "json":
+		return zapcore.NewJSONEncoder(cfg), nil
+	case "console":
+		return zapcore.NewConsoleEncoder(cfg), nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding `%s`, expect json or console", encoding)
+	}
+}
+
+func parseLevel(text string) (zapcore.Level, error) {
+	if text == "" {
+		return zapcore.InfoLevel, nil
+	}
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(text)); err != nil {
+		return level, fmt.Errorf("bad level `%s`, %w", text, err)
+	}
+	return level, nil
+}
@@ -0,0 +1,268 @@
+package alog_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/csh0101/alog"
+	"github.com/csh0101/alog/types"
+	"github.com/csh0101/alog/writers"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewLoggerFromConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed, %v", err)
+	}
+	defer listener.Close()
+	received := make(chan string, 100)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			received <- scanner.Text()
+		}
+	}()
+
+	path := filepath.Join(dir, "alog.yaml")
+	writeConfig(t, path, fmt.Sprintf(`
+level: debug
+watchInterval: 10ms
+encoder:
+  timeKey: "-"
+writers:
+  - type: file
+    file: {dir: %s, prefix: svc, ext: .log, fileMode: 0600}
+  - type: tcp
+    address: %s
+    level: error
+    encoding: console
+`, filepath.Join(dir, "a"), listener.Addr()))
+
+	logger, err := alog.NewLoggerFromConfigFile("svc", path)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	defer logger.Close()
+
+	logger.Debug("debug-to-file")
+	logger.Error("error-to-both", zap.String("k", "v"))
+
+	select {
+	case line := <-received:
+		if !strings.Contains(line, "ERROR") || !strings.Contains(line, "error-to-both") || strings.Contains(line, "{\"level\"") {
+			t.Fatalf("unexpected line from tcp writer, %s", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing received by tcp writer")
+	}
+	lines := readLines(t, filepath.Join(dir, "a"))
+	if len(lines) != 2 || !strings.Contains(lines[0], "debug-to-file") || strings.Contains(lines[0], `"ts"`) {
+		t.Fatalf("unexpected lines from file writer, %v", lines)
+	}
+	info, err := os.Stat(filepath.Join(dir, "a", filepath.Base(lineFiles(t, filepath.Join(dir, "a"))[0])))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("file mode is not applied, %v %v", info, err)
+	}
+
+	// log while the writers are being replaced, no entry should be lost
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	logged := make([]int, 4)
+	for i := range logged {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				logger.Info("in-flight")
+				logged[i]++
+			}
+		}(i)
+	}
+
+	writeConfig(t, path, fmt.Sprintf(`
+level: info
+verbose: 2
+watchInterval: 10ms
+writers:
+  - type: file
+    file: {dir: %s, prefix: svc, ext: .log}
+`, filepath.Join(dir, "b")))
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if files, _ := filepath.Glob(filepath.Join(dir, "b", "*.log")); len(files) > 0 && logger.(types.LogLevelInspector).LogVerbose() == 2 {
+			break
+		}
+	}
+	close(stop)
+	wg.Wait()
+	if logger.Enabled(zapcore.DebugLevel) || logger.(types.LogLevelInspector).LogVerbose() != 2 {
+		t.Fatal("config is not reloaded")
+	}
+	logger.Warn("after-reload")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("close failed, %v", err)
+	}
+
+	total := 0
+	for _, n := range logged {
+		total += n
+	}
+	var inFlight int
+	for _, line := range append(readLines(t, filepath.Join(dir, "a")), readLines(t, filepath.Join(dir, "b"))...) {
+		if strings.Contains(line, "in-flight") {
+			inFlight++
+		}
+	}
+	if inFlight != total {
+		t.Fatalf("entries lost during reload, logged %d, written %d", total, inFlight)
+	}
+	if lines := readLines(t, filepath.Join(dir, "b")); !strings.Contains(lines[len(lines)-1], "after-reload") {
+		t.Fatalf("new writer is not used, %v", lines[len(lines)-1])
+	}
+}
+
+func TestNewLoggerFromConfigFile_BadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "alog.json")
+	writeConfig(t, path, `{"level": "info", "writers": [{"type": "stdout"}], "watchInterval": "10ms"}`)
+
+	logger, err := alog.NewLoggerFromConfigFile("svc", path)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	defer logger.Close()
+
+	// a bad config is ignored
+	writeConfig(t, path, `{"level": "debug", "writers": [{"type": "carrier-pigeon"}]}`)
+	time.Sleep(100 * time.Millisecond)
+	if logger.Enabled(zapcore.DebugLevel) {
+		t.Fatal("bad config must not be applied")
+	}
+
+	for _, content := range []string{
+		`{"level": "loud"}`,
+		`{"lavel": "info"}`,
+		`{"writers": [{"type": "file"}]}`,
+		`{"encoding": "xml"}`,
+	} {
+		writeConfig(t, path, content)
+		if _, err := alog.NewLoggerFromConfigFile("svc", path); err == nil {
+			t.Fatalf("bad config %s must be rejected", content)
+		}
+	}
+}
+
+func TestNewLoggerFromConfigFile_ReloadSameDir(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "alog.yaml")
+	logDir := filepath.Join(dir, "logs")
+	writeConfig(t, path, fmt.Sprintf(`
+level: info
+watchInterval: 10ms
+writers:
+  - type: file
+    file: {dir: %s, prefix: svc, ext: .log, hashChain: true}
+`, logDir))
+
+	logger, err := alog.NewLoggerFromConfigFile("svc", path)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	defer logger.Close()
+	logger.Info("before-reload")
+
+	// the running writer of the dir is kept, a second one would fork the hash chain
+	writeConfig(t, path, fmt.Sprintf(`
+level: debug
+watchInterval: 10ms
+writers:
+  - type: file
+    file: {dir: %s, prefix: other, ext: .log, hashChain: true}
+`, logDir))
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline) && !logger.Enabled(zapcore.DebugLevel); time.Sleep(time.Millisecond) {
+	}
+	if !logger.Enabled(zapcore.DebugLevel) {
+		t.Fatal("config is not reloaded")
+	}
+	logger.Debug("after-reload")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("close failed, %v", err)
+	}
+
+	files := lineFiles(t, logDir)
+	if len(files) != 1 || !strings.HasPrefix(filepath.Base(files[0]), "svc") {
+		t.Fatalf("a second writer is opened in the dir, %v", files)
+	}
+	if _, err := writers.VerifyHashChainDir(logDir, "svc", ".log"); err != nil {
+		t.Fatalf("verify hash chain failed, %v", err)
+	}
+
+	// two file writers of one config must not share a dir
+	writeConfig(t, path, fmt.Sprintf(`
+writers:
+  - type: file
+    file: {dir: %s, prefix: a}
+  - type: file
+    file: {dir: %s, prefix: b}
+`, logDir, logDir))
+	if _, err := alog.NewLoggerFromConfigFile("svc", path); err == nil {
+		t.Fatal("file writers sharing a dir must be rejected")
+	}
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+
+	// write and rename, so the watcher never reads a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatalf("write config failed, %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("rename config failed, %v", err)
+	}
+}
+
+func lineFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no log file found in `%s`, %v", dir, err)
+	}
+	return files
+}
+
+func readLines(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var lines []string
+	for _, file := range lineFiles(t, dir) {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read file failed, %v", err)
+		}
+		lines = append(lines, strings.Split(strings.TrimSpace(string(b)), "\n")...)
+	}
+	return lines
+}
@@ -0,0 +1,60 @@
+package alog
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// maxStackDepth limits the frames captured by Errorf and WithStack.
+const maxStackDepth = 32
+
+// Errorf formats an error as fmt.Errorf does, with the stack of the caller,
+// which is encoded by the loggers with options.WithRichErrors.
+func Errorf(format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	var causes []error
+	switch u := err.(type) {
+	case interface{ Unwrap() []error }:
+		causes = u.Unwrap()
+	default:
+		if cause := errors.Unwrap(err); cause != nil {
+			causes = []error{cause}
+		}
+	}
+	return &stackError{err: err, causes: causes, stack: callers()}
+}
+
+// WithStack annotates err with the stack of the caller, it returns nil if err is nil.
+func WithStack(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &stackError{err: err, causes: []error{err}, stack: callers()}
+}
+
+type stackError struct {
+	err error
+	// causes are the errors wrapped by err if err is built by Errorf, or err itself
+	causes []error
+	stack  []uintptr
+}
+
+func (e *stackError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stackError) Unwrap() []error {
+	return e.causes
+}
+
+// StackTrace returns the program counters of the stack.
+func (e *stackError) StackTrace() []uintptr {
+	return e.stack
+}
+
+func callers() []uintptr {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(3, pcs[:])
+	return pcs[:n]
+}
@@ -0,0 +1,81 @@
+package alog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/csh0101/alog"
+	"github.com/csh0101/alog/options"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRichErrors(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := alog.NewLogger("svc",
+		options.WithWriter(buf),
+		options.WithRichErrors(true),
+		options.WithStacktraceLevel(zapcore.ErrorLevel),
+	)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	_, openErr := os.Open("/not/exist")
+	cause := alog.WithStack(openErr)
+	joined := errors.Join(alog.Errorf("load config failed, %w", cause), errors.New("fallback failed"))
+	logger.With(zap.NamedError("init", errors.New("plain"))).Warn("failed", zap.Error(joined))
+	logger.Error("stacktrace")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %s", buf.String())
+	}
+	var entry struct {
+		Init       string `json:"init"`
+		InitType   string `json:"initType"`
+		Error      string `json:"error"`
+		ErrorType  string `json:"errorType"`
+		Stacktrace string `json:"stacktrace"`
+		Causes     []struct {
+			Type string `json:"type"`
+			Msg  string `json:"msg"`
+		} `json:"errorCauses"`
+		Stack []struct {
+			Function string `json:"function"`
+			File     string `json:"file"`
+			Line     int    `json:"line"`
+		} `json:"errorStack"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("unmarshal failed, %v", err)
+	}
+	if entry.Init != "plain" || entry.InitType != "*errors.errorString" || entry.Error != joined.Error() || entry.ErrorType != "*errors.joinError" {
+		t.Fatalf("unexpected error fields, %s", lines[0])
+	}
+	var types []string
+	for _, c := range entry.Causes {
+		types = append(types, c.Type)
+	}
+	// joined > Errorf > WithStack > *fs.PathError > syscall.Errno, joined > fallback
+	if got := strings.Join(types, ","); got != "*alog.stackError,*alog.stackError,*fs.PathError,syscall.Errno,*errors.errorString" {
+		t.Fatalf("unexpected causes, %s", got)
+	}
+	var pathErr *fs.PathError
+	if !errors.As(joined, &pathErr) {
+		t.Fatal("causes must be kept by the wrappers")
+	}
+	// the innermost stack is the one of WithStack
+	if len(entry.Stack) == 0 || !strings.HasSuffix(entry.Stack[0].File, "errors_test.go") || entry.Stack[0].Function != "github.com/csh0101/alog_test.TestRichErrors" {
+		t.Fatalf("unexpected stack, %+v", entry.Stack)
+	}
+	if !strings.Contains(lines[1], `"stacktrace"`) || strings.Contains(lines[0], `"stacktrace"`) {
+		t.Fatalf("stacktrace level is not applied, %s", buf.String())
+	}
+}
@@ -0,0 +1,364 @@
+package alog
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// registry holds the global logger and the named loggers, gen is increased on every change of them.
+var registry = struct {
+	mutex   sync.RWMutex
+	global  types.Logger
+	loggers map[string]types.Logger
+	gen     atomic.Uint64
+}{
+	global:  newNopLogger(),
+	loggers: make(map[string]types.Logger),
+}
+
+var (
+	globalLogger = &proxyLogger{}
+	// helperLogger is used by the package-level logging functions, which are one more frame above the caller.
+	helperLogger = &proxyLogger{skip: 1}
+)
+
+func newNopLogger() types.Logger {
+	logger, err := azap.NewLogger("nop", options.WithCore(zapcore.NewNopCore()))
+	if err != nil {
+		panic(err)
+	}
+	return logger
+}
+
+// ReplaceGlobal replaces the global logger and returns a function to restore the previous one.
+// Loggers returned by L, S and Get before the replacement log by the new logger as well.
+// A logger returned by L or Get is replaced by the logger it resolves to at the moment.
+func ReplaceGlobal(logger types.Logger) func() {
+	if logger == nil {
+		logger = newNopLogger()
+	}
+	logger = unwrapProxy(logger)
+	registry.mutex.Lock()
+	prev := registry.global
+	registry.global = logger
+	registry.gen.Add(1)
+	registry.mutex.Unlock()
+
+	return func() {
+		ReplaceGlobal(prev)
+	}
+}
+
+// Register registers the logger by name, a nil logger unregisters it.
+// A logger returned by L or Get is registered as the logger it resolves to at the moment.
+func Register(name string, logger types.Logger) {
+	if logger != nil {
+		logger = unwrapProxy(logger)
+	}
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if logger == nil {
+		delete(registry.loggers, name)
+	} else {
+		registry.loggers[name] = logger
+	}
+	registry.gen.Add(1)
+}
+
+// L returns the global logger, which is a no-op logger until ReplaceGlobal is called.
+// Close of it closes the global logger and all registered loggers.
+func L() types.Logger {
+	return globalLogger
+}
+
+// S returns the sugared global logger.
+func S() types.SugaredLogger {
+	return globalLogger.Sugar()
+}
+
+// Get returns the logger registered by name, or the child of the global logger named by name
+// if no logger is registered. It follows the later replacements and registrations.
+func Get(name string) types.Logger {
+	return &proxyLogger{name: name}
+}
+
+// Debug logs a message at DebugLevel by the global logger.
+func Debug(msg string, fields ...zapcore.Field) {
+	helperLogger.Debug(msg, fields...)
+}
+
+// Info logs a message at InfoLevel by the global logger.
+func Info(msg string, fields ...zapcore.Field) {
+	helperLogger.Info(msg, fields...)
+}
+
+// Warn logs a message at WarnLevel by the global logger.
+func Warn(msg string, fields ...zapcore.Field) {
+	helperLogger.Warn(msg, fields...)
+}
+
+// Error logs a message at ErrorLevel by the global logger.
+func Error(msg string, fields ...zapcore.Field) {
+	helperLogger.Error(msg, fields...)
+}
+
+// Panic logs a message at PanicLevel by the global logger, and then panics.
+func Panic(msg string, fields ...zapcore.Field) {
+	helperLogger.Panic(msg, fields...)
+}
+
+// Fatal logs a message at FatalLevel by the global logger, closes it and then runs its fatal action,
+// os.Exit(1) by default, see options.WithFatalAction. Fatal panics if the action returns.
+func Fatal(msg string, fields ...zapcore.Field) {
+	helperLogger.Fatal(msg, fields...)
+}
+
+var (
+	_ types.Logger                = (*proxyLogger)(nil)
+	_ types.LogLevelsHotReloader  = (*proxyLogger)(nil)
+	_ types.LogVModuleHotReloader = (*proxyLogger)(nil)
+	_ types.LogLevelInspector     = (*proxyLogger)(nil)
+	_ types.LogSettingsInspector  = (*proxyLogger)(nil)
+	_ types.LogStatsInspector     = (*proxyLogger)(nil)
+	_ types.LogSugarFunc          = (*proxyLogger)(nil)
+	_ types.LogWriterFunc         = (*proxyLogger)(nil)
+	_ types.LogRecoverFunc        = (*proxyLogger)(nil)
+	_ azap.EntryLogger            = (*proxyLogger)(nil)
+	_ azap.FatalEntryLogger       = (*proxyLogger)(nil)
+)
+
+// nopInspector is the types.LogLevelInspector of the loggers not implementing it.
+type nopInspector struct{}
+
+func (nopInspector) LogLevel() zapcore.Level { return zapcore.InfoLevel }
+func (nopInspector) LogLevels() string       { return "" }
+func (nopInspector) LogVerbose() int         { return 0 }
+func (nopInspector) LogVModule() string      { return "" }
+
+// proxyLogger resolves the logger of the registry on every call, and caches it until the registry changes.
+type proxyLogger struct {
+	// name is the name in the registry, empty for the global logger
+	name string
+	// derive are the calls of Named, With and V on the proxy, replayed on the resolved logger
+	derive []func(types.Logger) types.Logger
+	// skip is the caller skip besides the frame of proxyLogger
+	skip int
+
+	target atomic.Pointer[proxyTarget]
+}
+
+type proxyTarget struct {
+	gen uint64
+	// logger is the resolved logger, caller is it with the caller skip of the proxy
+	logger types.Logger
+	caller types.Logger
+}
+
+// unwrapProxy returns the logger resolved by a proxy, as a proxy in the registry would resolve to itself.
+func unwrapProxy(logger types.Logger) types.Logger {
+	if p, ok := logger.(*proxyLogger); ok {
+		return p.resolve().logger
+	}
+	return logger
+}
+
+func (p *proxyLogger) resolve() *proxyTarget {
+	gen := registry.gen.Load()
+	if t := p.target.Load(); t != nil && t.gen == gen {
+		return t
+	}
+
+	registry.mutex.RLock()
+	logger, ok := registry.loggers[p.name]
+	if !ok || p.name == "" {
+		logger = registry.global
+		if p.name != "" {
+			logger = logger.Named(p.name)
+		}
+	}
+	registry.mutex.RUnlock()
+
+	for _, derive := range p.derive {
+		logger = derive(logger)
+	}
+	t := &proxyTarget{
+		gen:    gen,
+		logger: logger,
+		caller: azap.AddCallerSkip(logger, 1+p.skip),
+	}
+	p.target.Store(t)
+	return t
+}
+
+func (p *proxyLogger) with(derive func(types.Logger) types.Logger) *proxyLogger {
+	return &proxyLogger{
+		name:   p.name,
+		derive: append(p.derive[:len(p.derive):len(p.derive)], derive),
+		skip:   p.skip,
+	}
+}
+
+// Close closes the resolved logger, and all registered loggers if it is the global logger.
+func (p *proxyLogger) Close() error {
+	logger := p.resolve().logger
+	if p.name != "" || len(p.derive) > 0 {
+		return logger.Close()
+	}
+
+	registry.mutex.RLock()
+	loggers := make([]types.Logger, 0, len(registry.loggers))
+	for _, l := range registry.loggers {
+		if l != logger {
+			loggers = append(loggers, l)
+		}
+	}
+	registry.mutex.RUnlock()
+
+	err := logger.Close()
+	for _, l := range loggers {
+		err = multierr.Append(err, l.Close())
+	}
+	return err
+}
+
+func (p *proxyLogger) Enabled(level zapcore.Level) bool {
+	return p.resolve().caller.Enabled(level)
+}
+
+func (p *proxyLogger) HotReloadLogLevel(level zapcore.Level) error {
+	return p.resolve().logger.HotReloadLogLevel(level)
+}
+
+func (p *proxyLogger) HotReloadLogLevels(rules string) error {
+	if r, ok := p.resolve().logger.(types.LogLevelsHotReloader); ok {
+		return r.HotReloadLogLevels(rules)
+	}
+	return types.ErrUnsupported
+}
+
+func (p *proxyLogger) HostReloadLogVerbose(verbose int) error {
+	return p.resolve().logger.HostReloadLogVerbose(verbose)
+}
+
+func (p *proxyLogger) HotReloadLogVModule(rules string) error {
+	if r, ok := p.resolve().logger.(types.LogVModuleHotReloader); ok {
+		return r.HotReloadLogVModule(rules)
+	}
+	return types.ErrUnsupported
+}
+
+// inspector returns the resolved logger as a types.LogLevelInspector, which reports zero values if it is not one.
+func (p *proxyLogger) inspector() types.LogLevelInspector {
+	if i, ok := p.resolve().logger.(types.LogLevelInspector); ok {
+		return i
+	}
+	return nopInspector{}
+}
+
+func (p *proxyLogger) LogLevel() zapcore.Level {
+	return p.inspector().LogLevel()
+}
+
+func (p *proxyLogger) LogLevels() string {
+	return p.inspector().LogLevels()
+}
+
+func (p *proxyLogger) LogVerbose() int {
+	return p.inspector().LogVerbose()
+}
+
+func (p *proxyLogger) LogVModule() string {
+	return p.inspector().LogVModule()
+}
+
+func (p *proxyLogger) LogSettings() []types.LogSetting {
+	if i, ok := p.resolve().logger.(types.LogSettingsInspector); ok {
+		return i.LogSettings()
+	}
+	return nil
+}
+
+func (p *proxyLogger) LogStats() types.LogStats {
+	if i, ok := p.resolve().logger.(types.LogStatsInspector); ok {
+		return i.LogStats()
+	}
+	return types.LogStats{}
+}
+
+func (p *proxyLogger) Named(n string) types.Logger {
+	return p.with(func(l types.Logger) types.Logger { return l.Named(n) })
+}
+
+func (p *proxyLogger) V(verbose int) types.Logger {
+	return p.with(func(l types.Logger) types.Logger { return l.V(verbose) })
+}
+
+func (p *proxyLogger) With(fields ...zapcore.Field) types.Logger {
+	return p.with(func(l types.Logger) types.Logger { return l.With(fields...) })
+}
+
+func (p *proxyLogger) Sugar() types.SugaredLogger {
+	sugared := &proxyLogger{
+		name:   p.name,
+		derive: p.derive,
+		skip:   p.skip + 2,
+	}
+	return azap.NewSugaredLogger(p, sugared)
+}
+
+func (p *proxyLogger) Writer(level zapcore.Level) io.WriteCloser {
+	return azap.NewLineWriter(p, level)
+}
+
+// Recover must be deferred directly, the panic is logged by the resolved logger.
+func (p *proxyLogger) Recover(ctx context.Context, opts ...types.RecoverOption) {
+	if r := recover(); r != nil {
+		azap.LogPanic(p, ctx, r, opts...)
+	}
+}
+
+func (p *proxyLogger) EntryEnabled(ent zapcore.Entry) bool {
+	return azap.EntryEnabled(p.resolve().logger, ent)
+}
+
+func (p *proxyLogger) LogEntry(ent zapcore.Entry, fields ...zapcore.Field) {
+	azap.LogEntry(p.resolve().logger, ent, fields...)
+}
+
+func (p *proxyLogger) LogFatalEntry(ent zapcore.Entry, fields ...zapcore.Field) {
+	azap.LogFatalEntry(p.resolve().logger, ent, fields...)
+}
+
+func (p *proxyLogger) Debug(msg string, fields ...zapcore.Field) {
+	p.resolve().caller.Debug(msg, fields...)
+}
+
+func (p *proxyLogger) Info(msg string, fields ...zapcore.Field) {
+	p.resolve().caller.Info(msg, fields...)
+}
+
+func (p *proxyLogger) Warn(msg string, fields ...zapcore.Field) {
+	p.resolve().caller.Warn(msg, fields...)
+}
+
+func (p *proxyLogger) Error(msg string, fields ...zapcore.Field) {
+	p.resolve().caller.Error(msg, fields...)
+}
+
+func (p *proxyLogger) Panic(msg string, fields ...zapcore.Field) {
+	p.resolve().caller.Panic(msg, fields...)
+}
+
+func (p *proxyLogger) Fatal(msg string, fields ...zapcore.Field) {
+	p.resolve().caller.Fatal(msg, fields...)
+}
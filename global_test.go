@@ -0,0 +1,97 @@
+package alog_test
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/csh0101/alog"
+	"github.com/csh0101/alog/options"
+
+	"go.uber.org/zap"
+)
+
+type syncBuffer struct {
+	bytes.Buffer
+	synced atomic.Int32
+}
+
+func (b *syncBuffer) Sync() error {
+	b.synced.Add(1)
+	return nil
+}
+
+func TestGlobal(t *testing.T) {
+	early := alog.Get("billing").With(zap.String("k", "v"))
+	early.Info("dropped by the no-op logger")
+
+	global, registered := &syncBuffer{}, &syncBuffer{}
+	logger, err := alog.NewLogger("svc", options.WithWriter(global))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	restore := alog.ReplaceGlobal(logger)
+	defer restore()
+
+	early.Info("early")
+	alog.Info("helper")
+	alog.S().Infof("sugared %d", 1)
+	alog.L().Named("db").V(1).Info("v1 dropped")
+
+	lines := strings.Split(strings.TrimSpace(global.String()), "\n")
+	expected := [][]string{
+		{`"logger":"svc.billing"`, `"msg":"early"`, `"k":"v"`},
+		{`"logger":"svc"`, `"msg":"helper"`},
+		{`"logger":"svc"`, `"msg":"sugared 1"`},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %s", len(expected), global.String())
+	}
+	for i, line := range lines {
+		for _, s := range append(expected[i], "/global_test.go:") {
+			if !strings.Contains(line, s) {
+				t.Fatalf("`%s` is missing, got %s", s, line)
+			}
+		}
+	}
+
+	billing, err := alog.NewLogger("billing", options.WithWriter(registered))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	alog.Register("billing", billing)
+	defer alog.Register("billing", nil)
+	early.Info("registered")
+	if !strings.Contains(registered.String(), `"msg":"registered"`) || !strings.Contains(registered.String(), `"k":"v"`) {
+		t.Fatalf("registered logger is not used, got %s", registered.String())
+	}
+
+	if err := alog.L().Close(); err != nil {
+		t.Fatalf("close failed, %v", err)
+	}
+	if global.synced.Load() == 0 || registered.synced.Load() == 0 {
+		t.Fatal("all registered loggers must be flushed by Close of the global logger")
+	}
+}
+
+func TestGlobal_RegisterProxy(t *testing.T) {
+	buf := &syncBuffer{}
+	logger, err := alog.NewLogger("svc", options.WithWriter(buf))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	restore := alog.ReplaceGlobal(logger)
+	defer restore()
+
+	// proxies are unwrapped, instead of resolving to themselves
+	defer alog.ReplaceGlobal(alog.L())()
+	alog.Register("proxy", alog.Get("proxy").Named("child"))
+	defer alog.Register("proxy", nil)
+
+	alog.Info("global")
+	alog.Get("proxy").Info("registered")
+	if !strings.Contains(buf.String(), `"msg":"global"`) || !strings.Contains(buf.String(), `"logger":"svc.proxy.child"`) {
+		t.Fatalf("unexpected entries, %s", buf.String())
+	}
+}
@@ -2,6 +2,8 @@ package options
 
 import (
 	"io"
+	"os"
+	"time"
 
 	"github.com/csh0101/alog/types"
 	"go.uber.org/zap/zapcore"
@@ -9,6 +11,7 @@ import (
 
 // LoggerOption is an abstract for log options.
 // It enables you to the customize logger instance at initialization.
+// An option beyond types.LogOptionFuncs is ignored by a logger not implementing its interface.
 type LoggerOption func(logger types.LogOptionFuncs)
 
 // WithLogLevel option resets default log level at initializaton.
@@ -19,6 +22,16 @@ func WithLogLevel(v zapcore.Level) LoggerOption {
 	}
 }
 
+// WithLogLevels option sets per-module log levels by logger name, e.g. `svc.db.*=debug,svc.http=warn`.
+// Loggers matching no rule use the log level. Bad rules fail the creation of the logger.
+func WithLogLevels(rules string) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogRulesOptionFuncs); ok {
+			o.LogLevelRulesOption(rules)
+		}
+	}
+}
+
 // WithWriter option resets the default writer that logs will be written to.
 // You can specify multiple writers at the same time. Default is stderr.
 func WithWriter(w ...io.Writer) LoggerOption {
@@ -27,6 +40,16 @@ func WithWriter(w ...io.Writer) LoggerOption {
 	}
 }
 
+// WithOwnedWriter resets the default writer as WithWriter does, and hands the writers over to the logger.
+// Close of the logger flushes, syncs and closes them in order, e.g. a FileWriter.
+func WithOwnedWriter(w ...io.Writer) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogOwnedWriterOptionFunc); ok {
+			o.LogOwnedWriterOption(w...)
+		}
+	}
+}
+
 // WithStructuredFormat decides the format to output logs.
 // If true, logs will be printed as json format, otherwise text format instead.
 func WithStructuredFormat(v bool) LoggerOption {
@@ -55,3 +78,163 @@ func WithVerboseFilter(v int) LoggerOption {
 		logger.LogVerboseFilterOption(v)
 	}
 }
+
+// WithVModule sets glog-style per-file verbose rules, e.g. `gopher*=3,server=2`.
+// Bad rules fail the creation of the logger.
+func WithVModule(rules string) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogRulesOptionFuncs); ok {
+			o.LogVModuleOption(rules)
+		}
+	}
+}
+
+// WithEnv enables overriding the settings by environment variables with the prefix, default is ALOG.
+// The environment overrides the options regardless of their order, e.g. ALOG_LEVEL=debug overrides WithLogLevel.
+//
+//   - {prefix}_LEVEL, {prefix}_LEVELS, {prefix}_VERBOSE, {prefix}_VMODULE
+//   - {prefix}_FORMAT                  :  json or console
+//   - {prefix}_DISABLE_CALLER          :  true or false
+//   - {prefix}_FILE_DIR                :  writes to a writers.FileWriter instead of the writers
+//   - {prefix}_FILE_PREFIX, {prefix}_FILE_EXT, {prefix}_FILE_MAX_SIZE, {prefix}_FILE_RETENTION,
+//     {prefix}_FILE_TOTAL_COUNT_LIMIT, {prefix}_FILE_MODE
+//
+// Empty variables are ignored and bad ones fail the creation of the logger, so do FORMAT and FILE_* with WithCore,
+// as a custom core has its own encoder and sinks.
+// Use LogSettings of the logger to see where every setting comes from.
+func WithEnv(prefix string) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogEnvOptionFunc); ok {
+			o.LogEnvOption(prefix)
+		}
+	}
+}
+
+// WithStacktraceLevel captures the stacktrace of the entries at or above the level, default is FATAL.
+func WithStacktraceLevel(level zapcore.Level) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogErrorOptionFuncs); ok {
+			o.LogStacktraceLevelOption(level)
+		}
+	}
+}
+
+// WithRichErrors encodes the error fields, e.g. zap.Error(err), with the types of the error and its causes
+// walked by errors.Unwrap and errors.Join, and the stack carried by the error, e.g. by alog.Errorf.
+func WithRichErrors(v bool) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogErrorOptionFuncs); ok {
+			o.LogRichErrorsOption(v)
+		}
+	}
+}
+
+// WithFatalExitCode exits the process with code after a fatal log, default is 1.
+func WithFatalExitCode(code int) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogFatalActionOptionFunc); ok {
+			o.LogFatalActionOption(func(zapcore.Entry) { os.Exit(code) })
+		}
+	}
+}
+
+// WithFatalAction calls action instead of os.Exit(1) after a fatal log, see types.FatalAction.
+func WithFatalAction(action types.FatalAction) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogFatalActionOptionFunc); ok {
+			o.LogFatalActionOption(action)
+		}
+	}
+}
+
+// WithFatalPanic panics instead of exiting after a fatal log, so that the fatal paths can be tested by recover.
+func WithFatalPanic() LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogFatalActionOptionFunc); ok {
+			o.LogFatalActionOption(func(ent zapcore.Entry) { panic("fatal: " + ent.Message) })
+		}
+	}
+}
+
+// WithHook calls hook synchronously with the entries logged at or above minLevel, e.g. to bump a counter on ERROR.
+// A panic of the hook is recovered and reported to stderr, the hook must return quickly as it blocks the logging.
+func WithHook(minLevel zapcore.Level, hook types.LogHook) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogHookOptionFunc); ok {
+			o.LogHookOption(minLevel, hook, 0)
+		}
+	}
+}
+
+// WithAsyncHook calls hook in its own goroutine with the entries logged at or above minLevel, e.g. to forward to a webhook.
+// Entries are dropped if the queue of queueSize entries is full, entries above ERROR wait up to 100ms for room
+// as the process may exit after them. Close of the logger waits up to 1s for the queued entries,
+// and reports a hook not finished in time, so a hanging hook never blocks the logger.
+func WithAsyncHook(minLevel zapcore.Level, hook types.LogHook, queueSize int) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if queueSize <= 0 {
+			queueSize = 1
+		}
+		if o, ok := logger.(types.LogHookOptionFunc); ok {
+			o.LogHookOption(minLevel, hook, queueSize)
+		}
+	}
+}
+
+// WithDedup collapses the repeats of an entry with the same level, logger name, message and the given fields
+// within the window, e.g. thousands of "connection refused" while a dependency is down.
+// The first entry is written at once, a summary with repeated, first_seen and last_seen is written after the window.
+// At most maxKeys entries are tracked, the oldest one is flushed when it is exceeded, 0 for the default 1024.
+// Entries above ERROR are never collapsed, the summaries are flushed by Close of the logger.
+func WithDedup(window time.Duration, maxKeys int, fields ...string) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogDropOptionFuncs); ok {
+			o.LogDedupOption(window, maxKeys, fields...)
+		}
+	}
+}
+
+// WithSampling samples the entries at the level: in each tick, the first entries with the same message are logged,
+// then every thereafter-th one, 0 to drop all the others. A zero tick is 1s and a zero first is 1.
+// Levels without sampling are never sampled. Errors are never sampled away, so NewLogger fails
+// for ERROR and above, as it does for negative arguments.
+// The dropped entries are counted by LogStats of the logger.
+func WithSampling(level zapcore.Level, tick time.Duration, first, thereafter int) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogDropOptionFuncs); ok {
+			o.LogSamplingOption(level, tick, first, thereafter)
+		}
+	}
+}
+
+// WithRateLimit limits the entries at the level to perSecond with bursts up to burst,
+// by a token bucket per call site or per message, a zero burst is 1. Levels without rate limits are never limited.
+// NewLogger fails for ERROR and above, for perSecond <= 0 and for a negative burst.
+// The dropped entries are counted by LogStats of the logger.
+func WithRateLimit(level zapcore.Level, key types.RateLimitKey, perSecond float64, burst int) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogDropOptionFuncs); ok {
+			o.LogRateLimitOption(level, key, perSecond, burst)
+		}
+	}
+}
+
+// WithWrapCore wraps the core that the logger writes to.
+// It is useful to tee logs into an extra core, e.g. an in-memory observer in tests.
+func WithWrapCore(f func(zapcore.Core) zapcore.Core) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogCoreOptionFuncs); ok {
+			o.LogWrapCoreOption(f)
+		}
+	}
+}
+
+// WithCore sets the core that logs are written to, instead of the one built from the writers
+// and the format. The log level is still applied, e.g. to use the cores of azap.Sinks.
+func WithCore(core zapcore.Core) LoggerOption {
+	return func(logger types.LogOptionFuncs) {
+		if o, ok := logger.(types.LogCoreOptionFuncs); ok {
+			o.LogCoreOption(core)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package options
+
+import (
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// WithRecoverLevel logs the recovered panics at level, default is ERROR.
+func WithRecoverLevel(level zapcore.Level) types.RecoverOption {
+	return func(o *types.RecoverOptions) {
+		o.Level = level
+	}
+}
+
+// WithRecoverPolicy decides what to do after logging a recovered panic, default is types.RecoverRepanic.
+func WithRecoverPolicy(policy types.RecoverPolicy) types.RecoverOption {
+	return func(o *types.RecoverOptions) {
+		o.Policy = policy
+	}
+}
+
+// WithRecoverMessage sets the message of the log, default is `panic recovered`.
+func WithRecoverMessage(msg string) types.RecoverOption {
+	return func(o *types.RecoverOptions) {
+		if msg != "" {
+			o.Message = msg
+		}
+	}
+}
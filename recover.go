@@ -0,0 +1,25 @@
+package alog
+
+import (
+	"context"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/types"
+)
+
+// Go runs f in a new goroutine, in which the panics are logged by logger.Recover with opts,
+// or by azap.LogPanic if the logger does not implement types.LogRecoverFunc.
+func Go(logger types.Logger, f func(), opts ...types.RecoverOption) {
+	go func() {
+		if r, ok := logger.(types.LogRecoverFunc); ok {
+			defer r.Recover(context.Background(), opts...)
+		} else {
+			defer func() {
+				if r := recover(); r != nil {
+					azap.LogPanic(logger, context.Background(), r, opts...)
+				}
+			}()
+		}
+		f()
+	}()
+}
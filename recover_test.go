@@ -0,0 +1,38 @@
+package alog_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/csh0101/alog"
+	"github.com/csh0101/alog/options"
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestGo(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logged := make(chan struct{})
+	logger, err := alog.NewLogger("svc",
+		options.WithWriter(buf),
+		options.WithHook(zapcore.ErrorLevel, func(zapcore.Entry, []zapcore.Field) { close(logged) }),
+	)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	restore := alog.ReplaceGlobal(logger)
+	defer restore()
+
+	alog.Go(alog.L(), func() {
+		panic("worker failed")
+	}, options.WithRecoverPolicy(types.RecoverSwallow))
+	<-logged
+	if err := logger.Close(); err != nil {
+		t.Fatalf("close failed, %v", err)
+	}
+	if !strings.Contains(buf.String(), `"panic":"worker failed"`) || !strings.Contains(buf.String(), "/recover_test.go:") {
+		t.Fatalf("panic is not logged, got %s", buf.String())
+	}
+}
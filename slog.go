@@ -0,0 +1,301 @@
+package alog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/options"
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SlogHandler returns a slog.Handler writing the records by the logger.
+//
+// The levels of slog are mapped to the levels and the verbose of alog:
+//   - LevelError and above are ERROR, LevelWarn and above are WARN, LevelInfo and above are INFO,
+//   - levels between LevelDebug and LevelInfo are INFO of V(-level), e.g. Level(-2) is V(2).Info, as go-logr does,
+//   - LevelDebug is DEBUG, and levels below it are DEBUG of V(LevelDebug-level).
+//
+// Groups are nested objects and groups without attributes are omitted.
+// The caller and the time of the records are kept if the logger is an azap.EntryLogger.
+func SlogHandler(logger types.Logger) slog.Handler {
+	return &slogHandler{logger: logger, verbose: &sync.Map{}}
+}
+
+type slogHandler struct {
+	logger types.Logger
+	// groups are opened by WithGroup but not added to the logger yet, as groups without attributes are omitted
+	groups []string
+	// verbose caches the V loggers of logger by verbose, shared by the handlers with the same logger
+	verbose *sync.Map
+}
+
+// Enabled only checks the level, the verbose is checked in Handle against the caller of the record.
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	zapLevel, _ := slogLevelToZap(level)
+	return h.logger.Enabled(zapLevel)
+}
+
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	level, verbose := slogLevelToZap(r.Level)
+	logger := h.logger
+	if verbose > 0 {
+		logger = h.verboseLogger(verbose)
+	}
+
+	ent := zapcore.Entry{
+		Level:   level,
+		Time:    r.Time,
+		Message: r.Message,
+	}
+	if ent.Time.IsZero() {
+		ent.Time = time.Now()
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		ent.Caller = zapcore.NewEntryCaller(r.PC, frame.File, frame.Line, true)
+	}
+
+	fields := make([]zapcore.Field, 0, len(h.groups)+r.NumAttrs())
+	fields = appendNamespaces(fields, h.groups)
+	n := len(fields)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendSlogAttr(fields, a)
+		return true
+	})
+	if len(fields) == n {
+		fields = fields[:0]
+	}
+	azap.LogEntry(logger, ent, fields...)
+	return nil
+}
+
+func (h *slogHandler) verboseLogger(verbose int) types.Logger {
+	if logger, ok := h.verbose.Load(verbose); ok {
+		return logger.(types.Logger)
+	}
+	logger, _ := h.verbose.LoadOrStore(verbose, h.logger.V(verbose))
+	return logger.(types.Logger)
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := appendNamespaces(nil, h.groups)
+	n := len(fields)
+	for _, a := range attrs {
+		fields = appendSlogAttr(fields, a)
+	}
+	if len(fields) == n {
+		return h
+	}
+	return &slogHandler{logger: h.logger.With(fields...), verbose: &sync.Map{}}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{
+		logger:  h.logger,
+		groups:  append(h.groups[:len(h.groups):len(h.groups)], name),
+		verbose: h.verbose,
+	}
+}
+
+func slogLevelToZap(level slog.Level) (zapcore.Level, int) {
+	switch {
+	case level >= slog.LevelError:
+		return zapcore.ErrorLevel, 0
+	case level >= slog.LevelWarn:
+		return zapcore.WarnLevel, 0
+	case level >= slog.LevelInfo:
+		return zapcore.InfoLevel, 0
+	case level > slog.LevelDebug:
+		return zapcore.InfoLevel, int(slog.LevelInfo - level)
+	default:
+		return zapcore.DebugLevel, int(slog.LevelDebug - level)
+	}
+}
+
+func appendNamespaces(fields []zapcore.Field, groups []string) []zapcore.Field {
+	for _, g := range groups {
+		fields = append(fields, zap.Namespace(g))
+	}
+	return fields
+}
+
+func appendSlogAttr(fields []zapcore.Field, a slog.Attr) []zapcore.Field {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		attrs := v.Group()
+		if len(attrs) == 0 {
+			return fields
+		}
+		// a group without key is inlined
+		if a.Key == "" {
+			for _, a := range attrs {
+				fields = appendSlogAttr(fields, a)
+			}
+			return fields
+		}
+		return append(fields, zap.Object(a.Key, slogGroup(attrs)))
+	}
+	if a.Key == "" {
+		return fields
+	}
+
+	switch v.Kind() {
+	case slog.KindString:
+		return append(fields, zap.String(a.Key, v.String()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(a.Key, v.Int64()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(a.Key, v.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(a.Key, v.Float64()))
+	case slog.KindBool:
+		return append(fields, zap.Bool(a.Key, v.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(a.Key, v.Duration()))
+	case slog.KindTime:
+		return append(fields, zap.Time(a.Key, v.Time()))
+	}
+	if err, ok := v.Any().(error); ok {
+		return append(fields, zap.NamedError(a.Key, err))
+	}
+	return append(fields, zap.Any(a.Key, v.Any()))
+}
+
+type slogGroup []slog.Attr
+
+func (g slogGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	var fields []zapcore.Field
+	for _, a := range g {
+		fields = appendSlogAttr(fields, a)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return nil
+}
+
+// NewSlogLogger new a logger writing the logs through the slog.Handler, e.g. slog.NewJSONHandler.
+// Levels, verbose and the other settings of alog still apply before the handler.
+//
+// The levels of alog are mapped to the ones of slog, and the levels above ERROR are mapped to LevelError+1 and above.
+// The logger name is the attribute `logger`, and the fields after zap.Namespace are grouped.
+func NewSlogLogger(loggerName string, handler slog.Handler, opts ...options.LoggerOption) (types.Logger, error) {
+	return NewLogger(loggerName, append(opts, options.WithCore(&slogCore{handler: handler}))...)
+}
+
+type slogCore struct {
+	handler slog.Handler
+}
+
+func (c *slogCore) Enabled(level zapcore.Level) bool {
+	return c.handler.Enabled(context.Background(), zapLevelToSlog(level))
+}
+
+func (c *slogCore) With(fields []zapcore.Field) zapcore.Core {
+	handler := c.handler
+	var attrs []slog.Attr
+	for _, f := range fields {
+		if f.Type != zapcore.NamespaceType {
+			attrs = appendZapField(attrs, f)
+			continue
+		}
+		if len(attrs) > 0 {
+			handler, attrs = handler.WithAttrs(attrs), nil
+		}
+		handler = handler.WithGroup(f.Key)
+	}
+	if len(attrs) > 0 {
+		handler = handler.WithAttrs(attrs)
+	}
+	return &slogCore{handler: handler}
+}
+
+func (c *slogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *slogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	r := slog.NewRecord(ent.Time, zapLevelToSlog(ent.Level), ent.Message, ent.Caller.PC)
+	if ent.LoggerName != "" {
+		r.AddAttrs(slog.String("logger", ent.LoggerName))
+	}
+	r.AddAttrs(zapFieldsToSlog(fields)...)
+	if ent.Stack != "" {
+		r.AddAttrs(slog.String("stacktrace", ent.Stack))
+	}
+	return c.handler.Handle(context.Background(), r)
+}
+
+func (c *slogCore) Sync() error {
+	return nil
+}
+
+func zapLevelToSlog(level zapcore.Level) slog.Level {
+	switch {
+	case level <= zapcore.DebugLevel:
+		return slog.LevelDebug
+	case level == zapcore.InfoLevel:
+		return slog.LevelInfo
+	case level == zapcore.WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError + slog.Level(level-zapcore.ErrorLevel)
+	}
+}
+
+// zapFieldsToSlog converts the fields into attributes, the fields after a namespace are grouped.
+func zapFieldsToSlog(fields []zapcore.Field) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for i, f := range fields {
+		if f.Type == zapcore.NamespaceType {
+			if group := zapFieldsToSlog(fields[i+1:]); len(group) > 0 {
+				attrs = append(attrs, slog.Attr{Key: f.Key, Value: slog.GroupValue(group...)})
+			}
+			break
+		}
+		attrs = appendZapField(attrs, f)
+	}
+	return attrs
+}
+
+func appendZapField(attrs []slog.Attr, f zapcore.Field) []slog.Attr {
+	switch f.Type {
+	case zapcore.StringType:
+		return append(attrs, slog.String(f.Key, f.String))
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		return append(attrs, slog.Int64(f.Key, f.Integer))
+	case zapcore.BoolType:
+		return append(attrs, slog.Bool(f.Key, f.Integer == 1))
+	case zapcore.DurationType:
+		return append(attrs, slog.Duration(f.Key, time.Duration(f.Integer)))
+	case zapcore.SkipType:
+		return attrs
+	}
+
+	// the others are encoded as zap does, e.g. an error field may have `error` and `errorVerbose`
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	keys := make([]string, 0, len(enc.Fields))
+	for k := range enc.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, slog.Any(k, enc.Fields[k]))
+	}
+	return attrs
+}
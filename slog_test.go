@@ -0,0 +1,133 @@
+package alog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/csh0101/alog"
+	"github.com/csh0101/alog/options"
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSlogHandler(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := alog.NewLogger("svc",
+		options.WithWriter(buf),
+		options.WithLogLevel(zapcore.DebugLevel),
+		options.WithVerboseFilter(1),
+	)
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	s := slog.New(alog.SlogHandler(logger)).With("k", "v").WithGroup("req").With("id", 7).WithGroup("empty")
+	s.Info("with groups", "n", 1, slog.Group("sub", "a", true), slog.Group("nothing"))
+	s.Warn("no attrs")
+	s.Log(context.Background(), slog.Level(-1), "v1")
+	s.Log(context.Background(), slog.Level(-2), "v2")
+	s.Log(context.Background(), slog.LevelDebug-1, "debug-v1")
+	s.Log(context.Background(), slog.LevelError+4, "error", "err", errors.New("boom"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []map[string]interface{}{
+		{"level": "INFO", "msg": "with groups", "k": "v", "req": map[string]interface{}{
+			"id": 7.0, "empty": map[string]interface{}{"n": 1.0, "sub": map[string]interface{}{"a": true}},
+		}},
+		{"level": "WARN", "msg": "no attrs", "k": "v", "req": map[string]interface{}{"id": 7.0}},
+		{"level": "INFO", "msg": "v1"},
+		{"level": "DEBUG", "msg": "debug-v1"},
+		{"level": "ERROR", "msg": "error", "req": map[string]interface{}{"id": 7.0, "empty": map[string]interface{}{"err": "boom"}}},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %s", len(expected), buf.String())
+	}
+	for i, line := range lines {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("unmarshal failed, %v", err)
+		}
+		for k, v := range expected[i] {
+			if b1, _ := json.Marshal(m[k]); string(b1) != mustMarshal(v) {
+				t.Fatalf("%s mismatch, expected %s, got %s", k, mustMarshal(v), b1)
+			}
+		}
+		if !strings.Contains(m["caller"].(string), "/slog_test.go:") {
+			t.Fatalf("bad caller, %s", line)
+		}
+	}
+}
+
+func TestSlogHandler_VerboseCached(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := alog.NewLogger("svc", options.WithWriter(buf), options.WithVerboseFilter(2))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+	counting := &vCountingLogger{Logger: logger}
+	slogger := slog.New(alog.SlogHandler(counting)).WithGroup("g")
+	for i := 0; i < 3; i++ {
+		slogger.Log(context.Background(), slog.Level(-2), "verbose", "i", i)
+	}
+	if counting.calls != 1 || strings.Count(buf.String(), `"msg":"verbose"`) != 3 {
+		t.Fatalf("expected V called once for 3 records, got %d, %s", counting.calls, buf.String())
+	}
+}
+
+type vCountingLogger struct {
+	types.Logger
+	calls int
+}
+
+func (l *vCountingLogger) V(verbose int) types.Logger {
+	l.calls++
+	return l.Logger.V(verbose)
+}
+
+func TestNewSlogLogger(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	handler := slog.NewJSONHandler(buf, &slog.HandlerOptions{AddSource: true, Level: slog.LevelDebug})
+	logger, err := alog.NewSlogLogger("svc", handler, options.WithVerboseFilter(1))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	logger.Debug("debug")
+	logger.V(2).Info("v2")
+	logger.Named("db").With(zap.String("k", "v"), zap.Namespace("ns"), zap.Int("n", 1)).
+		Warn("warn", zap.Error(errors.New("boom")), zap.Namespace("inner"), zap.Strings("s", []string{"a"}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %s", buf.String())
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatalf("unmarshal failed, %v", err)
+	}
+	delete(m, "time")
+	source, _ := m["source"].(map[string]interface{})
+	if file, _ := source["file"].(string); !strings.HasSuffix(file, "slog_test.go") {
+		t.Fatalf("bad source, %s", lines[0])
+	}
+	delete(m, "source")
+	expected := `{"k":"v","level":"WARN","msg":"warn","ns":{"error":"boom","inner":{"s":["a"]},"logger":"svc.db","n":1}}`
+	if got := mustMarshal(m); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func mustMarshal(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
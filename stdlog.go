@@ -0,0 +1,31 @@
+package alog
+
+import (
+	"log"
+
+	"github.com/csh0101/alog/azap"
+	"github.com/csh0101/alog/types"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// stdLogCallerSkip is the depth of Write of the output below the caller of the standard log package, e.g. log.Printf.
+const stdLogCallerSkip = 3
+
+// RedirectStdLog redirects the output of the standard log package to the logger at level.
+// Level prefixes such as [E] are recognized, the flags and the prefix of the standard logger are cleared
+// as the logger has its own time and caller. It returns a function to restore the standard logger.
+func RedirectStdLog(logger types.Logger, level zapcore.Level) func() {
+	flags, prefix, output := log.Flags(), log.Prefix(), log.Writer()
+	w := azap.NewLineWriter(logger, level, azap.WithLineLevelPrefix(), azap.WithLineCallerSkip(stdLogCallerSkip))
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(w)
+
+	return func() {
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+		log.SetOutput(output)
+		_ = w.Close()
+	}
+}
@@ -0,0 +1,47 @@
+package alog_test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/csh0101/alog"
+	"github.com/csh0101/alog/options"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRedirectStdLog(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger, err := alog.NewLogger("svc", options.WithWriter(buf))
+	if err != nil {
+		t.Fatalf("new logger failed, %v", err)
+	}
+
+	defer log.SetOutput(log.Writer())
+	output := bytes.NewBuffer(nil)
+	log.SetOutput(output)
+
+	restore := alog.RedirectStdLog(logger, zapcore.InfoLevel)
+	log.Printf("hello %s", "std")
+	log.Println("[W] disk almost full")
+	restore()
+	log.Print("restored")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %s", buf.String())
+	}
+	for i, s := range []string{`"level":"INFO"`, `"level":"WARN"`} {
+		if !strings.Contains(lines[i], s) || !strings.Contains(lines[i], "/stdlog_test.go:") {
+			t.Fatalf("bad line, %s", lines[i])
+		}
+	}
+	if !strings.Contains(lines[1], `"msg":"disk almost full"`) {
+		t.Fatalf("prefix not recognized, %s", lines[1])
+	}
+	if !strings.Contains(output.String(), "restored") || strings.Contains(output.String(), "hello") {
+		t.Fatalf("std log is not restored, got %s", output.String())
+	}
+}
@@ -3,6 +3,7 @@ package types
 import (
 	"context"
 	"io"
+	"time"
 
 	"go.uber.org/zap/zapcore"
 )
@@ -14,6 +15,10 @@ type CtxLogger interface {
 
 // Logger interface provides a set of essential functions in log scenery.
 // The Close() function should be called before quit to ensure that all logs will be sync to the writer.
+//
+// The other capabilities are optional interfaces checked by type assertions, so implementations of Logger
+// are not broken by them, e.g. LogLevelsHotReloader, LogLevelInspector, LogSugarFunc and LogRecoverFunc.
+// The loggers of azap implement all of them.
 type Logger interface {
 	LogCloser
 	LogLevelEnabler
@@ -22,6 +27,8 @@ type Logger interface {
 	LogNamedFunc
 
 	V(verbose int) Logger
+	// With clones the logger and adds the fields to all of its logs.
+	With(fields ...zapcore.Field) Logger
 
 	Debug(msg string, fields ...zapcore.Field)
 	Info(msg string, fields ...zapcore.Field)
@@ -31,6 +38,46 @@ type Logger interface {
 	Fatal(msg string, fields ...zapcore.Field)
 }
 
+// LogSugarFunc wraps a logger to provide the printf-style and key-value API.
+type LogSugarFunc interface {
+	Sugar() SugaredLogger
+}
+
+// LogWriterFunc returns an io.Writer logging each line written to it at the level.
+// Close logs the last line without a line break.
+type LogWriterFunc interface {
+	Writer(level zapcore.Level) io.WriteCloser
+}
+
+// LogRecoverFunc recovers panics. Recover must be deferred directly, e.g. `defer logger.Recover(ctx)`. It logs
+// the recovered panic with the stack of the goroutine and the fields of ctx, then repanics, exits or returns by the policy.
+type LogRecoverFunc interface {
+	Recover(ctx context.Context, opts ...RecoverOption)
+}
+
+// SugaredLogger provides the printf-style API, e.g. Infof("user %s", id),
+// and the key-value API, e.g. Infow("msg", "k", v), in which a zapcore.Field is accepted as a pair.
+type SugaredLogger interface {
+	Debugf(template string, args ...interface{})
+	Infof(template string, args ...interface{})
+	Warnf(template string, args ...interface{})
+	Errorf(template string, args ...interface{})
+	Panicf(template string, args ...interface{})
+	Fatalf(template string, args ...interface{})
+
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+	Panicw(msg string, keysAndValues ...interface{})
+	Fatalw(msg string, keysAndValues ...interface{})
+
+	// With clones the logger and adds the key-value pairs to all of its logs.
+	With(keysAndValues ...interface{}) SugaredLogger
+	// Desugar returns the logger wrapped by the sugared logger.
+	Desugar() Logger
+}
+
 // LogCloser close logger and ensure all logs will be sync to the writer
 type LogCloser interface {
 	io.Closer
@@ -47,6 +94,14 @@ type LogLevelHotReloader interface {
 	HotReloadLogLevel(level zapcore.Level) error
 }
 
+// LogLevelsHotReloader enables you to update per-module log levels with hot-reload.
+// Rules are separated by comma and each rule is pattern=level, e.g. `svc.db.*=debug,svc.http=warn`.
+// A pattern matches the logger name, in which `*` matches any sequence of characters.
+// Loggers matching no rule use the level set by HotReloadLogLevel, an empty rules clears all rules.
+type LogLevelsHotReloader interface {
+	HotReloadLogLevels(rules string) error
+}
+
 // LogVerboseHotReloader enables you to update log verbose with hot-reload.
 // It will return ErrNotSupported if the implementation did not support it.
 //
@@ -55,7 +110,36 @@ type LogVerboseHotReloader interface {
 	HostReloadLogVerbose(verbose int) error
 }
 
+// LogVModuleHotReloader enables you to update glog-style vmodule rules with hot-reload.
+// Rules are separated by comma and each rule is file_pattern=N, e.g. `gopher*=3,server=2`.
+// A pattern is matched against the caller's file name without the `.go` suffix, or against the full path
+// if it contains `/`. Logs of V(n) are printed if n <= N of the first matching rule or n <= the verbose.
+type LogVModuleHotReloader interface {
+	HotReloadLogVModule(rules string) error
+}
+
+// LogLevelInspector returns the settings that can be updated with hot-reload.
+// LogLevels and LogVModule return the rules in the format they are set.
+type LogLevelInspector interface {
+	LogLevel() zapcore.Level
+	LogLevels() string
+	LogVerbose() int
+	LogVModule() string
+}
+
+// LogSettingsInspector returns the settings of a logger with their current values and sources,
+// to tell which of the defaults, options, environment and hot reloads took effect.
+type LogSettingsInspector interface {
+	LogSettings() []LogSetting
+}
+
+// LogStatsInspector returns the counters of a logger, e.g. the entries dropped by sampling.
+type LogStatsInspector interface {
+	LogStats() LogStats
+}
+
 // LogOptionFuncs interface provides a set of functions to init a logger instance.
+// The other options are optional interfaces, an option is ignored by a logger not implementing it.
 type LogOptionFuncs interface {
 	LogLevelOption(v zapcore.Level)
 	LogWriterOption(w ...io.Writer)
@@ -65,6 +149,58 @@ type LogOptionFuncs interface {
 	LogVerboseFilterOption(v int)
 }
 
+// LogOwnedWriterOptionFunc sets writers which are closed by the logger.
+type LogOwnedWriterOptionFunc interface {
+	LogOwnedWriterOption(w ...io.Writer)
+}
+
+// LogCoreOptionFuncs set or wrap the core of a logger.
+type LogCoreOptionFuncs interface {
+	LogWrapCoreOption(f func(zapcore.Core) zapcore.Core)
+	LogCoreOption(core zapcore.Core)
+}
+
+// LogEnvOptionFunc reads the settings of a logger from the environment.
+type LogEnvOptionFunc interface {
+	LogEnvOption(prefix string)
+}
+
+// LogRulesOptionFuncs set the per-module level rules and the vmodule rules.
+type LogRulesOptionFuncs interface {
+	LogLevelRulesOption(v string)
+	LogVModuleOption(v string)
+}
+
+// LogHookOptionFunc adds an entry hook.
+type LogHookOptionFunc interface {
+	LogHookOption(minLevel zapcore.Level, hook LogHook, queueSize int)
+}
+
+// LogDropOptionFuncs set the dedup, sampling and rate limits, which drop entries.
+type LogDropOptionFuncs interface {
+	LogDedupOption(window time.Duration, maxKeys int, fields ...string)
+	LogSamplingOption(level zapcore.Level, tick time.Duration, first, thereafter int)
+	LogRateLimitOption(level zapcore.Level, key RateLimitKey, perSecond float64, burst int)
+}
+
+// LogErrorOptionFuncs set how errors and stacks are encoded.
+type LogErrorOptionFuncs interface {
+	LogStacktraceLevelOption(v zapcore.Level)
+	LogRichErrorsOption(v bool)
+}
+
+// LogFatalActionOptionFunc sets the action of Fatal.
+type LogFatalActionOptionFunc interface {
+	LogFatalActionOption(action FatalAction)
+}
+
+// FatalAction is called by Fatal after the entry is written and the logger is closed, instead of os.Exit(1).
+// As the caller of Fatal does not expect it to return, Fatal panics if the action returns.
+type FatalAction func(ent zapcore.Entry)
+
+// LogHook is called with the entries logged at or above its level, and the fields of the logger and the entry.
+type LogHook func(ent zapcore.Entry, fields []zapcore.Field)
+
 // LogNamedFunc clones a logger and rename it.
 type LogNamedFunc interface {
 	Named(n string) Logger
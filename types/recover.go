@@ -0,0 +1,28 @@
+package types
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// RecoverPolicy is what Logger.Recover does after logging a recovered panic.
+type RecoverPolicy int
+
+const (
+	// RecoverRepanic panics again with the recovered value, it is the default.
+	RecoverRepanic RecoverPolicy = iota
+	// RecoverExit logs the panic at FatalLevel by the fatal path of the logger, which closes the logger
+	// and calls the fatal action, os.Exit(1) by default, see options.WithFatalAction.
+	RecoverExit
+	// RecoverSwallow returns normally.
+	RecoverSwallow
+)
+
+// RecoverOptions are the options of Logger.Recover.
+type RecoverOptions struct {
+	// Level is ERROR by default, a FATAL log does not exit by itself, see Policy.
+	Level   zapcore.Level
+	Policy  RecoverPolicy
+	Message string
+}
+
+type RecoverOption func(o *RecoverOptions)
@@ -0,0 +1,34 @@
+package types
+
+import "go.uber.org/zap/zapcore"
+
+// RateLimitKey decides which entries share a token bucket of a rate limit.
+type RateLimitKey int
+
+const (
+	// RateLimitByCallSite shares a bucket by the entries logged at the same line,
+	// it falls back to RateLimitByMessage if the caller is disabled.
+	RateLimitByCallSite RateLimitKey = iota
+	// RateLimitByMessage shares a bucket by the entries with the same logger name and message.
+	RateLimitByMessage
+)
+
+// LogStats are the counters of a logger, shared by the logger and all of its clones.
+type LogStats struct {
+	// Sampled is the number of entries dropped by sampling, by level.
+	Sampled map[zapcore.Level]uint64
+	// RateLimited is the number of entries dropped by the rate limits, by level.
+	RateLimited map[zapcore.Level]uint64
+}
+
+// Dropped returns the total number of dropped entries.
+func (s LogStats) Dropped() uint64 {
+	var n uint64
+	for _, c := range s.Sampled {
+		n += c
+	}
+	for _, c := range s.RateLimited {
+		n += c
+	}
+	return n
+}
@@ -0,0 +1,21 @@
+package types
+
+// LogSettingSource is where the value of a setting comes from.
+// A source overrides the ones before it: default < option < env < hot-reload.
+type LogSettingSource string
+
+const (
+	LogSettingFromDefault   LogSettingSource = "default"
+	LogSettingFromOption    LogSettingSource = "option"
+	LogSettingFromEnv       LogSettingSource = "env"
+	LogSettingFromHotReload LogSettingSource = "hot-reload"
+)
+
+// LogSetting is a setting of a logger.
+type LogSetting struct {
+	Name   string
+	Value  string
+	Source LogSettingSource
+	// Env is the environment variable that overrides the setting, empty if env is not enabled.
+	Env string
+}
@@ -0,0 +1,29 @@
+package writers
+
+import "io"
+
+// segmentCodec frames the entries written into a segment file.
+//
+// A codec keeps the state of the segment being written: header is called once
+// for every new segment and encode for every entry appended to it. Neither of
+// them changes the state, which is only advanced by commitHeader and commit
+// after the bytes are written, so a failed write never desyncs the codec from
+// the segment on disk.
+type segmentCodec interface {
+	// header returns the bytes which start a new segment.
+	header() ([]byte, error)
+	// commitHeader switches to the segment whose header was returned last.
+	commitHeader()
+	// size returns the framed size of an entry with n bytes.
+	size(n int) int
+	// encode frames an entry.
+	encode(b []byte) ([]byte, error)
+	// commit advances the state past the entry framed last.
+	commit()
+}
+
+// segmentResumer is implemented by codecs whose state is carried across segments.
+type segmentResumer interface {
+	// resume restores the state from the latest existing segment.
+	resume(r io.Reader) error
+}
@@ -0,0 +1,281 @@
+package writers
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Encrypted segment layout:
+//
+//	magic "ALOGENC1" | uint32 header length | JSON header | record...
+//
+// Every record is an uint32 length followed by the AES-GCM sealed entry. The nonce of
+// a record is its index in the segment, so records can't be dropped from the middle or
+// reordered without failing decryption. Records cut off from the end of a segment are
+// not detected, as there is no final record count. The header is not sealed, but a
+// tampered key id or wrapped key fails unwrapping or the decryption of every record.
+const (
+	encryptMagic        = "ALOGENC1"
+	encryptAlgorithm    = "AES-256-GCM"
+	encryptDataKeySize  = 32
+	encryptMaxRecordLen = 1 << 30
+)
+
+// KeyProvider wraps and unwraps the per-segment data keys of encrypted log files.
+type KeyProvider interface {
+	// WrapKey encrypts dataKey with the current master key and returns the id of that master key.
+	WrapKey(dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key which was wrapped by the master key with the given id.
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+type encryptHeader struct {
+	Algorithm  string `json:"alg"`
+	KeyID      string `json:"keyId"`
+	WrappedKey []byte `json:"wrappedKey"`
+}
+
+type encryptCodec struct {
+	kp    KeyProvider
+	aead  cipher.AEAD
+	index uint64
+	// pending is the AEAD of the segment whose header is not written yet
+	pending cipher.AEAD
+}
+
+func newEncryptCodec(kp KeyProvider) *encryptCodec {
+	return &encryptCodec{kp: kp}
+}
+
+func (c *encryptCodec) header() ([]byte, error) {
+	dataKey := make([]byte, encryptDataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("generate data key failed, %w", err)
+	}
+	keyID, wrapped, err := c.kp.WrapKey(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("wrap data key failed, %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(encryptHeader{
+		Algorithm:  encryptAlgorithm,
+		KeyID:      keyID,
+		WrappedKey: wrapped,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal header failed, %w", err)
+	}
+
+	c.pending = aead
+
+	b := make([]byte, 0, len(encryptMagic)+4+len(header))
+	b = append(b, encryptMagic...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(header)))
+	return append(b, header...), nil
+}
+
+func (c *encryptCodec) commitHeader() {
+	c.aead, c.index, c.pending = c.pending, 0, nil
+}
+
+func (c *encryptCodec) size(n int) int {
+	return 4 + n + c.aead.Overhead()
+}
+
+func (c *encryptCodec) encode(b []byte) ([]byte, error) {
+	if c.aead == nil {
+		return nil, errors.New("segment header is not written")
+	}
+	record := make([]byte, 4, c.size(len(b)))
+	record = c.aead.Seal(record, recordNonce(c.aead, c.index), b, nil)
+	binary.BigEndian.PutUint32(record, uint32(len(record)-4))
+	return record, nil
+}
+
+func (c *encryptCodec) commit() {
+	c.index++
+}
+
+// DecryptSegment reads an encrypted segment from src and writes the plain entries to dst.
+func DecryptSegment(dst io.Writer, src io.Reader, kp KeyProvider) error {
+	r := bufio.NewReader(src)
+
+	magic := make([]byte, len(encryptMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return fmt.Errorf("read magic failed, %w", err)
+	}
+	if string(magic) != encryptMagic {
+		return errors.New("not an encrypted segment")
+	}
+	headerBytes, err := readFrame(r)
+	if err != nil {
+		return fmt.Errorf("read header failed, %w", err)
+	}
+	var header encryptHeader
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return fmt.Errorf("unmarshal header failed, %w", err)
+	}
+	if header.Algorithm != encryptAlgorithm {
+		return fmt.Errorf("unsupported algorithm `%s`", header.Algorithm)
+	}
+	dataKey, err := kp.UnwrapKey(header.KeyID, header.WrappedKey)
+	if err != nil {
+		return fmt.Errorf("unwrap data key failed, %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return err
+	}
+
+	var plain []byte
+	for index := uint64(0); ; index++ {
+		record, err := readFrame(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read record %d failed, %w", index, err)
+		}
+		plain, err = aead.Open(plain[:0], recordNonce(aead, index), record, nil)
+		if err != nil {
+			return fmt.Errorf("decrypt record %d failed, %w", index, err)
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+	}
+}
+
+// readFrame reads an uint32 length-prefixed frame. It returns io.EOF only if r
+// ends right before the frame.
+func readFrame(r io.Reader) ([]byte, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(lenBuf[:])
+	if n > encryptMaxRecordLen {
+		return nil, fmt.Errorf("frame length %d is too large", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+func recordNonce(aead cipher.AEAD, index uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], index)
+	return nonce
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("new cipher failed, %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("new gcm failed, %w", err)
+	}
+	return aead, nil
+}
+
+var _ KeyProvider = &StaticKeyProvider{}
+
+// StaticKeyProvider is a KeyProvider holding AES master keys in memory.
+// Data keys are wrapped with AES-GCM by the current master key.
+type StaticKeyProvider struct {
+	mutex     sync.RWMutex
+	currentID string
+	keys      map[string][]byte
+}
+
+// NewStaticKeyProvider new a key provider which wraps data keys with keys[currentID].
+// Every key must be 16, 24 or 32 bytes long.
+func NewStaticKeyProvider(currentID string, keys map[string][]byte) (*StaticKeyProvider, error) {
+	kp := &StaticKeyProvider{keys: make(map[string][]byte, len(keys))}
+	for id, key := range keys {
+		if err := kp.add(id, key); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := kp.keys[currentID]; !ok {
+		return nil, fmt.Errorf("current key `%s` not found", currentID)
+	}
+	kp.currentID = currentID
+	return kp, nil
+}
+
+// Rotate adds a master key and makes it the current one. Keys rotated out
+// are kept to unwrap the data keys of existing segments.
+func (kp *StaticKeyProvider) Rotate(keyID string, key []byte) error {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	if err := kp.add(keyID, key); err != nil {
+		return err
+	}
+	kp.currentID = keyID
+	return nil
+}
+
+func (kp *StaticKeyProvider) add(keyID string, key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return fmt.Errorf("invalid size %d of key `%s`", len(key), keyID)
+	}
+	kp.keys[keyID] = append([]byte(nil), key...)
+	return nil
+}
+
+func (kp *StaticKeyProvider) WrapKey(dataKey []byte) (string, []byte, error) {
+	kp.mutex.RLock()
+	keyID, key := kp.currentID, kp.keys[kp.currentID]
+	kp.mutex.RUnlock()
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("generate nonce failed, %w", err)
+	}
+	return keyID, aead.Seal(nonce, nonce, dataKey, []byte(keyID)), nil
+}
+
+func (kp *StaticKeyProvider) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	kp.mutex.RLock()
+	key, ok := kp.keys[keyID]
+	kp.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("key `%s` not found", keyID)
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped key is too short")
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, []byte(keyID))
+}
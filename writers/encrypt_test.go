@@ -0,0 +1,312 @@
+package writers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/csh0101/alog/alogtest"
+	"github.com/csh0101/alog/writers"
+)
+
+func TestFileWriter_Encryption(t *testing.T) {
+	dir := t.TempDir()
+
+	kp, err := writers.NewStaticKeyProvider("k1", map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+	})
+	if err != nil {
+		t.Fatalf("new key provider failed, %v", err)
+	}
+
+	w, err := writers.NewFileWriter(dir,
+		writers.WithFilePrefix("test"),
+		writers.WithFileExt(".log"),
+		writers.WithFileMaxSizeInBytes(300),
+		writers.WithEncryption(kp),
+	)
+	if err != nil {
+		t.Fatalf("new file writer failed, %v", err)
+	}
+
+	var expected []string
+	for i := 0; i < 6; i++ {
+		if i == 3 {
+			if err := kp.Rotate("k2", bytes.Repeat([]byte{2}, 32)); err != nil {
+				t.Fatalf("rotate key failed, %v", err)
+			}
+		}
+		line := fmt.Sprintf("{\"msg\":\"hello encryption\",\"index\":%d}\n", i)
+		expected = append(expected, line)
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("write failed, %v", err)
+		}
+	}
+	w.Close()
+
+	files := segmentFiles(t, dir)
+	if len(files) < 2 {
+		t.Fatalf("expected rotated segments, got %d", len(files))
+	}
+
+	decrypted := bytes.NewBuffer(nil)
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read file failed, %v", err)
+		}
+		if bytes.Contains(b, []byte("hello encryption")) {
+			t.Fatalf("segment `%s` holds plain text", file)
+		}
+		if err := writers.DecryptSegment(decrypted, bytes.NewReader(b), kp); err != nil {
+			t.Fatalf("decrypt `%s` failed, %v", file, err)
+		}
+	}
+	if decrypted.String() != strings.Join(expected, "") {
+		t.Fatalf("decrypted content mismatch, got %q", decrypted.String())
+	}
+
+	// segments after the rotation are wrapped by the new master key only
+	old, err := writers.NewStaticKeyProvider("k1", map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+	})
+	if err != nil {
+		t.Fatalf("new key provider failed, %v", err)
+	}
+	last, err := os.ReadFile(files[len(files)-1])
+	if err != nil {
+		t.Fatalf("read file failed, %v", err)
+	}
+	if err := writers.DecryptSegment(bytes.NewBuffer(nil), bytes.NewReader(last), old); err == nil {
+		t.Fatal("last segment must not be decrypted with the rotated out key only")
+	}
+}
+
+func TestDecryptSegment_Tampered(t *testing.T) {
+	dir := t.TempDir()
+
+	kp, err := writers.NewStaticKeyProvider("k1", map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 16),
+	})
+	if err != nil {
+		t.Fatalf("new key provider failed, %v", err)
+	}
+	w, err := writers.NewFileWriter(dir, writers.WithEncryption(kp))
+	if err != nil {
+		t.Fatalf("new file writer failed, %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := w.Write([]byte("{\"msg\":\"hello\"}\n")); err != nil {
+			t.Fatalf("write failed, %v", err)
+		}
+	}
+	w.Close()
+
+	files := segmentFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 segment, got %d", len(files))
+	}
+	b, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("read file failed, %v", err)
+	}
+
+	// flip a byte of the last record
+	tampered := append([]byte(nil), b...)
+	tampered[len(tampered)-1] ^= 0xff
+	if err := writers.DecryptSegment(bytes.NewBuffer(nil), bytes.NewReader(tampered), kp); err == nil {
+		t.Fatal("tampered segment must fail decryption")
+	}
+
+	// truncate the last record
+	if err := writers.DecryptSegment(bytes.NewBuffer(nil), bytes.NewReader(b[:len(b)-3]), kp); err == nil {
+		t.Fatal("truncated segment must fail decryption")
+	}
+}
+
+func TestFileWriter_EncryptionNeverAppends(t *testing.T) {
+	dir := t.TempDir()
+
+	kp, err := writers.NewStaticKeyProvider("k1", map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+	})
+	if err != nil {
+		t.Fatalf("new key provider failed, %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		w, err := writers.NewFileWriter(dir, writers.WithEncryption(kp))
+		if err != nil {
+			t.Fatalf("new file writer failed, %v", err)
+		}
+		if _, err := w.Write([]byte("{\"msg\":\"hello\"}\n")); err != nil {
+			t.Fatalf("write failed, %v", err)
+		}
+		w.Close()
+	}
+
+	files := segmentFiles(t, dir)
+	if len(files) != 2 {
+		t.Fatalf("expected a new segment for every writer, got %d", len(files))
+	}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read file failed, %v", err)
+		}
+		out := bytes.NewBuffer(nil)
+		if err := writers.DecryptSegment(out, bytes.NewReader(b), kp); err != nil {
+			t.Fatalf("decrypt `%s` failed, %v", file, err)
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+			t.Fatalf("bad decrypted entry %q, %v", out.String(), err)
+		}
+	}
+}
+
+func TestFileWriter_EncryptionWriteFailures(t *testing.T) {
+	dir := "/logs"
+	clock := alogtest.NewClock(time.Date(2024, 1, 1, 23, 59, 59, 0, time.UTC))
+	fs := &failFS{MemFS: writers.NewMemFS(clock)}
+	kp, err := writers.NewStaticKeyProvider("k1", map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+	})
+	if err != nil {
+		t.Fatalf("new key provider failed, %v", err)
+	}
+	w, err := writers.NewFileWriter(dir,
+		writers.WithFS(fs),
+		writers.WithClock(clock),
+		writers.WithFilePrefix("test"),
+		writers.WithFileExt(".log"),
+		writers.WithEncryption(kp),
+	)
+	if err != nil {
+		t.Fatalf("new file writer failed, %v", err)
+	}
+	defer w.Close()
+
+	write := func(i int, fail bool) {
+		t.Helper()
+		if _, err := w.Write([]byte(fmt.Sprintf("%d\n", i))); (err != nil) != fail {
+			t.Fatalf("write %d, expected failure %v, got %v", i, fail, err)
+		}
+	}
+	write(0, false)
+	// a failed write leaves no gap in the record indexes
+	fs.failNext(1, false)
+	write(1, true)
+	write(2, false)
+	// a short write breaks the segment, the next entries go to a new one
+	fs.failNext(1, true)
+	write(3, true)
+	write(4, false)
+	// a failed header keeps writing the current segment with its own key
+	fs.failNext(1, false)
+	clock.Advance(time.Second)
+	for deadline := time.Now().Add(5 * time.Second); fs.pending() > 0 && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+	}
+	write(5, false)
+	// a broken segment is never appended to, even if the rotation fails
+	fs.failNext(3, true)
+	write(6, true)
+	write(7, true)
+	write(8, false)
+
+	for name, expected := range map[string]struct {
+		plain  string
+		broken bool
+	}{
+		"test-20240101-0000.log": {"0\n2\n", true},
+		"test-20240101-0001.log": {"4\n5\n", true},
+		"test-20240102-0003.log": {"8\n", false},
+	} {
+		b, err := fs.ReadFile(dir + "/" + name)
+		if err != nil {
+			t.Fatalf("read file failed, %v", err)
+		}
+		out := bytes.NewBuffer(nil)
+		err = writers.DecryptSegment(out, bytes.NewReader(b), kp)
+		if out.String() != expected.plain || (err != nil) != expected.broken {
+			t.Fatalf("decrypt `%s`, expected %q broken %v, got %q, %v", name, expected.plain, expected.broken, out.String(), err)
+		}
+	}
+}
+
+// failFS injects write failures into the files of a MemFS.
+type failFS struct {
+	*writers.MemFS
+
+	mutex sync.Mutex
+	fails int
+	short bool
+}
+
+// failNext fails the next n writes, after writing half of the bytes if short is true.
+func (fs *failFS) failNext(n int, short bool) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	fs.fails, fs.short = n, short
+}
+
+func (fs *failFS) pending() int {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	return fs.fails
+}
+
+func (fs *failFS) OpenFile(name string, flag int, perm os.FileMode) (writers.File, error) {
+	f, err := fs.MemFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &failFile{File: f, fs: fs}, nil
+}
+
+type failFile struct {
+	writers.File
+	fs *failFS
+}
+
+func (f *failFile) Write(b []byte) (int, error) {
+	f.fs.mutex.Lock()
+	fail, short := f.fs.fails > 0, f.fs.short
+	if fail {
+		f.fs.fails--
+	}
+	f.fs.mutex.Unlock()
+
+	if !fail {
+		return f.File.Write(b)
+	}
+	var n int
+	if short {
+		n, _ = f.File.Write(b[:len(b)/2])
+	}
+	return n, errors.New("injected write failure")
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir failed, %v", err)
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(files)
+	return files
+}
@@ -23,15 +23,29 @@ type FileWriter struct {
 	cancel context.CancelFunc
 
 	logger              *log.Logger
+	fs                  FS
+	clock               Clock
 	dir                 string
 	fileMaxSizeInBytes  int64
 	fileRetention       time.Duration
 	fileTotalCountLimit int
 	filePrefix          string
 	fileExt             string
+	fileMode            os.FileMode
+	dirMode             os.FileMode
+	fileUID             int
+	fileGID             int
+	keyProvider         KeyProvider
+	hashChain           bool
+	chainKey            []byte
+	chainAnchor         func(head string)
+	archiver            Archiver
+	codec               segmentCodec
 
 	mutex sync.RWMutex
 	f     *safeCloseFile
+	// broken is set when a partial entry was written to f, no entry is appended to f anymore
+	broken bool
 }
 
 func NewFileWriter(dir string, opts ...FileWriterOption) (*FileWriter, error) {
@@ -41,15 +55,28 @@ func NewFileWriter(dir string, opts ...FileWriterOption) (*FileWriter, error) {
 
 	w := &FileWriter{
 		logger:              log.New(io.Discard, "", log.LstdFlags),
+		fs:                  OSFS(),
+		clock:               SystemClock(),
 		dir:                 dir,
 		fileMaxSizeInBytes:  2 * 1024 * 1024 * 1024,
 		fileRetention:       7 * 24 * time.Hour,
 		fileTotalCountLimit: 10000,
+		fileUID:             -1,
+		fileGID:             -1,
 	}
 	for _, opt := range opts {
 		opt(w)
 	}
 
+	switch {
+	case w.keyProvider != nil && w.hashChain:
+		return nil, errors.New("encryption and hash chain can not be enabled at the same time")
+	case w.keyProvider != nil:
+		w.codec = newEncryptCodec(w.keyProvider)
+	case w.hashChain:
+		w.codec = newChainCodec(w.chainKey, w.chainAnchor)
+	}
+
 	if err := w.init(); err != nil {
 		return nil, fmt.Errorf("init failed, %w", err)
 	}
@@ -58,15 +85,26 @@ func NewFileWriter(dir string, opts ...FileWriterOption) (*FileWriter, error) {
 }
 
 func (w *FileWriter) init() error {
-	if err := os.MkdirAll(w.dir, os.ModePerm); err != nil {
+	dirMode := w.dirMode
+	if dirMode == 0 {
+		dirMode = os.ModePerm
+	}
+	if err := w.fs.MkdirAll(w.dir, dirMode); err != nil {
 		return fmt.Errorf("create dir failed, %w", err)
 	}
+	if err := w.verifyAttrs(); err != nil {
+		return fmt.Errorf("verify file attributes failed, %w", err)
+	}
+
+	if err := w.resumeCodec(); err != nil {
+		return fmt.Errorf("resume codec failed, %w", err)
+	}
 
 	fileName, fileSequence, err := w.analysisFiles()
 	if err != nil {
 		return fmt.Errorf("analysis files failed, %w", err)
 	}
-	f, err := newSafeCloseFile(w.filePath(fileName, fileSequence))
+	f, err := w.openSegment(fileName, fileSequence)
 	if err != nil {
 		return fmt.Errorf("open current file failed, %w", err)
 	}
@@ -77,13 +115,18 @@ func (w *FileWriter) init() error {
 	w.cancel = cancel
 	w.f = f
 
-	go w.setupAutomationWorker()
+	// segments left by the last run were not archived yet
+	if w.archiver != nil {
+		w.archiveFinishedWithoutLock()
+	}
+
+	// the ticker is created before the worker starts, so no tick is missed
+	go w.setupAutomationWorker(w.clock.NewTicker(time.Second))
 
 	return nil
 }
 
-func (w *FileWriter) setupAutomationWorker() {
-	ticker := time.NewTicker(time.Second)
+func (w *FileWriter) setupAutomationWorker(ticker Ticker) {
 	defer ticker.Stop()
 
 	tickCount := int64(0)
@@ -93,7 +136,7 @@ func (w *FileWriter) setupAutomationWorker() {
 		case <-w.ctx.Done():
 			w.logger.Println("[I] automation worker exit")
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			w.mutex.Lock()
 
 			// 自动按天rotate
@@ -118,13 +161,43 @@ func (w *FileWriter) autoRotateByDayWithoutLock() {
 }
 
 func (w *FileWriter) autoRetentionWithoutLock() {
-	dirEntries, err := os.ReadDir(w.dir)
+	fileInfoList, err := w.listSegments()
 	if err != nil {
 		w.logger.Printf("[E] scan directory failed, %v", err)
 		return
 	}
 
-	// fileInfoList was sorted by filename asc
+	now := w.clock.Now()
+	for idx, info := range fileInfoList {
+		// Forbidden clearing the file that is in using
+		if filepath.Base(info.Name()) == filepath.Base(w.f.Name()) {
+			continue
+		}
+		// Clear condition:
+		//   (1) when the file was over the total count limit
+		//   (2) when the file was over the retention time
+		if (w.fileTotalCountLimit > 0 && len(fileInfoList)-idx > w.fileTotalCountLimit) ||
+			(w.fileRetention > 0 && info.ModTime().Add(w.fileRetention).Before(now)) {
+
+			// The archiver removes the file once it was archived
+			if w.archiver != nil {
+				w.archiver.Archive(filepath.Join(w.dir, info.Name()))
+				continue
+			}
+			_ = w.fs.Remove(filepath.Join(w.dir, info.Name()))
+			w.logger.Printf("[D] retention clear file `%s`\n", info.Name())
+			continue
+		}
+	}
+}
+
+// listSegments returns the segment files in the directory, sorted by filename asc.
+func (w *FileWriter) listSegments() ([]os.FileInfo, error) {
+	dirEntries, err := w.fs.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
 	fileInfoList := make([]os.FileInfo, 0, len(dirEntries))
 	for _, entry := range dirEntries {
 		fInfo, err := entry.Info()
@@ -141,24 +214,87 @@ func (w *FileWriter) autoRetentionWithoutLock() {
 		}
 		fileInfoList = append(fileInfoList, fInfo)
 	}
+	return fileInfoList, nil
+}
 
-	now := time.Now()
-	for idx, info := range fileInfoList {
-		// Forbidden clearing the file that is in using
-		if filepath.Base(info.Name()) == filepath.Base(w.f.Name()) {
-			continue
+// verifyAttrs applies the configured mode and ownership to the directory and the existing segments.
+func (w *FileWriter) verifyAttrs() error {
+	info, err := w.fs.Stat(w.dir)
+	if err != nil {
+		return fmt.Errorf("stat dir failed, %w", err)
+	}
+	if err := w.applyAttrs(w.dir, info, w.dirMode); err != nil {
+		return err
+	}
+
+	fileInfoList, err := w.listSegments()
+	if err != nil {
+		return fmt.Errorf("scan dir failed, %w", err)
+	}
+	for _, info := range fileInfoList {
+		if err := w.applyAttrs(filepath.Join(w.dir, info.Name()), info, w.fileMode); err != nil {
+			return err
 		}
-		// Clear condition:
-		//   (1) when the file was over the total count limit
-		//   (2) when the file was over the retention time
-		if (w.fileTotalCountLimit > 0 && len(fileInfoList)-idx > w.fileTotalCountLimit) ||
-			(w.fileRetention > 0 && info.ModTime().Add(w.fileRetention).Before(now)) {
+	}
+	return nil
+}
 
-			_ = os.Remove(filepath.Join(w.dir, info.Name()))
-			w.logger.Printf("[D] retention clear file `%s`\n", info.Name())
-			continue
+// applyAttrs changes the mode and ownership of path if they mismatch the configured ones.
+// Zero mode and negative ids are not configured and left as they are.
+func (w *FileWriter) applyAttrs(path string, info os.FileInfo, mode os.FileMode) error {
+	if mode != 0 && info.Mode().Perm() != mode.Perm() {
+		if err := w.fs.Chmod(path, mode.Perm()); err != nil {
+			return fmt.Errorf("chmod `%s` failed, %w", path, err)
 		}
+		w.logger.Printf("[D] change mode of `%s` from %v to %v\n", info.Name(), info.Mode().Perm(), mode.Perm())
+	}
+	if w.fileUID < 0 && w.fileGID < 0 {
+		return nil
+	}
+	if uid, gid, ok := fileOwner(info); ok &&
+		(w.fileUID < 0 || uid == w.fileUID) && (w.fileGID < 0 || gid == w.fileGID) {
+		return nil
 	}
+	if err := w.fs.Chown(path, w.fileUID, w.fileGID); err != nil {
+		return fmt.Errorf("chown `%s` failed, %w", path, err)
+	}
+	return nil
+}
+
+// resumeCodec restores the state of a codec carried across segments from the latest segment.
+func (w *FileWriter) resumeCodec() error {
+	resumer, ok := w.codec.(segmentResumer)
+	if !ok {
+		return nil
+	}
+	fileInfoList, err := w.listSegments()
+	if err != nil {
+		return fmt.Errorf("scan dir failed, %w", err)
+	}
+	// empty segments are left by failed header writes
+	for len(fileInfoList) > 0 && fileInfoList[len(fileInfoList)-1].Size() == 0 {
+		fileInfoList = fileInfoList[:len(fileInfoList)-1]
+	}
+	if len(fileInfoList) == 0 {
+		return nil
+	}
+
+	latest := filepath.Join(w.dir, fileInfoList[len(fileInfoList)-1].Name())
+	f, err := w.fs.OpenFile(latest, os.O_RDONLY, 0)
+	if err != nil {
+		return fmt.Errorf("open latest segment failed, %w", err)
+	}
+	defer f.Close()
+
+	if err := resumer.resume(f); err != nil {
+		return fmt.Errorf("resume from `%s` failed, %w", latest, err)
+	}
+	return nil
+}
+
+// Dir returns the directory of the segments.
+func (w *FileWriter) Dir() string {
+	return w.dir
 }
 
 func (w *FileWriter) Write(b []byte) (int, error) {
@@ -171,10 +307,15 @@ func (w *FileWriter) Write(b []byte) (int, error) {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
-	if int64(len(b))+w.f.Size() < w.fileMaxSizeInBytes {
-		return w.f.Write(b)
+	size := int64(len(b))
+	if w.codec != nil {
+		size = int64(w.codec.size(len(b)))
 	}
-	if int64(len(b)) > w.fileMaxSizeInBytes {
+
+	if size+w.f.Size() < w.fileMaxSizeInBytes {
+		return w.writeWithoutLock(b)
+	}
+	if size > w.fileMaxSizeInBytes {
 		return 0, fmt.Errorf("data to write is too large, it exceeds the max file size setting. Limit is %d bytes", w.fileMaxSizeInBytes)
 	}
 	select {
@@ -184,7 +325,34 @@ func (w *FileWriter) Write(b []byte) (int, error) {
 		w.rorateWithoutLock(false)
 	}
 
-	return w.f.Write(b)
+	return w.writeWithoutLock(b)
+}
+
+func (w *FileWriter) writeWithoutLock(b []byte) (int, error) {
+	if w.codec == nil {
+		return w.f.Write(b)
+	}
+	if w.broken {
+		w.rorateWithoutLock(false)
+		if w.broken {
+			return 0, errors.New("segment is broken by a partial entry and rotate failed")
+		}
+	}
+	record, err := w.codec.encode(b)
+	if err != nil {
+		return 0, fmt.Errorf("encode entry failed, %w", err)
+	}
+	if n, err := w.f.Write(record); err != nil {
+		// the partial record breaks the framing of the rest of the segment, and its nonce
+		// or chain head must not be used again, so the segment is never appended to
+		if n > 0 {
+			w.broken = true
+			w.rorateWithoutLock(false)
+		}
+		return 0, err
+	}
+	w.codec.commit()
+	return len(b), nil
 }
 
 func (w *FileWriter) Close() error {
@@ -211,22 +379,77 @@ func (w *FileWriter) rorateWithoutLock(isDayRotate bool) {
 	if !isDayRotate {
 		fileSequence++
 	}
-	f, err := newSafeCloseFile(w.filePath(fileName, fileSequence))
+	f, err := w.openSegment(fileName, fileSequence)
 	if err != nil {
 		w.logger.Printf("[E] open file to write failed, %v\n", err)
 		return
 	}
 	if w.f != nil {
 		w.f.Close()
+		if w.archiver != nil {
+			w.archiver.Archive(w.f.Name())
+		}
 	}
 	w.f = f
+	w.broken = false
+}
+
+// archiveFinishedWithoutLock hands all segments but the one in using to the archiver.
+func (w *FileWriter) archiveFinishedWithoutLock() {
+	fileInfoList, err := w.listSegments()
+	if err != nil {
+		w.logger.Printf("[E] scan directory failed, %v", err)
+		return
+	}
+	for _, info := range fileInfoList {
+		if filepath.Base(info.Name()) == filepath.Base(w.f.Name()) {
+			continue
+		}
+		w.archiver.Archive(filepath.Join(w.dir, info.Name()))
+	}
+}
+
+// openSegment opens the segment file to write. When entries are framed by a codec,
+// it never appends to an existing segment but skips to the next empty one and
+// writes the codec header first.
+func (w *FileWriter) openSegment(fileName string, fileSequence int) (*safeCloseFile, error) {
+	for {
+		path := w.filePath(fileName, fileSequence)
+		f, err := newSafeCloseFile(w.fs, path, w.fileMode)
+		if err != nil {
+			return nil, err
+		}
+		if err := w.applyAttrs(path, f.info, w.fileMode); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if w.codec == nil {
+			return f, nil
+		}
+		if f.Size() > 0 {
+			f.Close()
+			fileSequence++
+			continue
+		}
+		header, err := w.codec.header()
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("create segment header failed, %w", err)
+		}
+		if _, err := f.Write(header); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("write segment header failed, %w", err)
+		}
+		w.codec.commitHeader()
+		return f, nil
+	}
 }
 
 func (w *FileWriter) analysisFiles() (fileName string, fileSequence int, err error) {
 	fileSequence = 0
 	fileName = w.fileName()
 
-	dirEntries, err := os.ReadDir(w.dir)
+	dirEntries, err := w.fs.ReadDir(w.dir)
 	if err != nil {
 		return "", 0, fmt.Errorf("scan dir failed, %w", err)
 	}
@@ -276,7 +499,7 @@ func (w *FileWriter) fileName() string {
 	if w.filePrefix != "" {
 		fNameFields = append(fNameFields, w.filePrefix)
 	}
-	fNameFields = append(fNameFields, time.Now().UTC().Format("20060102"))
+	fNameFields = append(fNameFields, w.clock.Now().UTC().Format("20060102"))
 	return strings.Join(fNameFields, "-")
 }
 
@@ -328,21 +551,126 @@ func WithLogWriter(writer io.Writer) FileWriterOption {
 	}
 }
 
+// WithEncryption enables encrypting segment mode. Entries are written as AES-GCM records
+// with a data key generated for every segment, which is wrapped by kp and stored in the
+// segment header. Master key rotation of kp takes effect at the next segment.
+//
+// Use DecryptSegment or cmd/alog-decrypt to read the segments back.
+func WithEncryption(kp KeyProvider) FileWriterOption {
+	return func(w *FileWriter) {
+		w.keyProvider = kp
+	}
+}
+
+// WithHashChain enables tamper-evident segment mode. Every entry is prefixed with a
+// running SHA-256 hash chained to the previous entry, and the chain head is carried
+// into the header of the next segment, across rotations and restarts.
+//
+// An unkeyed chain only detects accidental damage, as anyone able to edit the segments can
+// recompute the hashes; see WithHashChainKey and WithHashChainAnchor against tampering.
+//
+// Use VerifyHashChainDir or cmd/alog-verify to detect edited or deleted entries.
+func WithHashChain() FileWriterOption {
+	return func(w *FileWriter) {
+		w.hashChain = true
+	}
+}
+
+// WithHashChainKey enables tamper-evident segment mode like WithHashChain, with the entries
+// chained by HMAC-SHA256 under key. Without the key, an edited entry can't be rehashed to
+// hide the edit, so keep the key away from the segments.
+//
+// Use VerifyHashChainDir with WithChainKey to verify the segments.
+func WithHashChainKey(key []byte) FileWriterOption {
+	return func(w *FileWriter) {
+		w.hashChain = true
+		w.chainKey = append([]byte(nil), key...)
+	}
+}
+
+// WithHashChainAnchor calls f with the hex chain head after every entry written in tamper-evident
+// segment mode, e.g. to store it out of reach of the segments. Verifying with WithChainHead
+// against the last head detects a truncated tail. f is called with the writer locked.
+func WithHashChainAnchor(f func(head string)) FileWriterOption {
+	return func(w *FileWriter) {
+		w.chainAnchor = f
+	}
+}
+
+// WithFileMode sets the permission bits of segment files, e.g. 0600 for logs holding PII.
+// It is applied regardless of umask on every segment opened, and to the existing segments
+// at startup. By default, segments are created with 0644 subject to umask.
+func WithFileMode(v os.FileMode) FileWriterOption {
+	return func(w *FileWriter) {
+		w.fileMode = v.Perm()
+	}
+}
+
+// WithDirMode sets the permission bits of the log directory, e.g. 0700.
+// It is applied regardless of umask at startup. By default, the directory
+// is created with os.ModePerm subject to umask.
+func WithDirMode(v os.FileMode) FileWriterOption {
+	return func(w *FileWriter) {
+		w.dirMode = v.Perm()
+	}
+}
+
+// WithFileOwner changes the owner of the log directory and segment files, the same way
+// as WithFileMode applies. A negative id is left as it is, e.g. WithFileOwner(-1, gid)
+// only changes the group.
+func WithFileOwner(uid, gid int) FileWriterOption {
+	return func(w *FileWriter) {
+		w.fileUID = uid
+		w.fileGID = gid
+	}
+}
+
+// WithArchiver hands finished segments to the archiver, on rotation and when retention
+// would clear them. Segments left by the last run are handed over at startup, so an
+// archiver retries them across restarts. The archiver removes the local segments itself.
+func WithArchiver(a Archiver) FileWriterOption {
+	return func(w *FileWriter) {
+		w.archiver = a
+	}
+}
+
+// WithFS stores segments in the given filesystem instead of the local one.
+func WithFS(fs FS) FileWriterOption {
+	return func(w *FileWriter) {
+		if fs != nil {
+			w.fs = fs
+		}
+	}
+}
+
+// WithClock drives rotation and retention by the given clock instead of the system one.
+func WithClock(clock Clock) FileWriterOption {
+	return func(w *FileWriter) {
+		if clock != nil {
+			w.clock = clock
+		}
+	}
+}
+
 type safeCloseFile struct {
 	once     sync.Once
 	info     os.FileInfo
 	fileSize int64
-	*os.File
+	File
 }
 
-func newSafeCloseFile(path string) (*safeCloseFile, error) {
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+func newSafeCloseFile(fs FS, path string, mode os.FileMode) (*safeCloseFile, error) {
+	if mode == 0 {
+		mode = 0644
+	}
+	f, err := fs.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, mode)
 	if err != nil {
 		return nil, err
 	}
 
 	info, err := f.Stat()
 	if err != nil {
+		f.Close()
 		return nil, fmt.Errorf("stat file failed, %w", err)
 	}
 
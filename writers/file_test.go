@@ -2,194 +2,290 @@ package writers_test
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/csh0101/alog/alogtest"
 	"github.com/csh0101/alog/writers"
 )
 
+var testStartTime = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
 func TestFileWriter(t *testing.T) {
 	var (
-		err        error
-		writeToDir = "./testdata/"
+		dir                = "/logs"
+		contentToWrite     = []byte("Hello, this is a file writer test")
+		fileRetention      = 5 * time.Second
+		maxFileSizeInBytes = 50
 	)
 
-	os.RemoveAll(writeToDir)
-	err = testFileWriter(writeToDir)
-	os.RemoveAll(writeToDir)
+	clock := alogtest.NewClock(testStartTime)
+	fs := writers.NewMemFS(clock)
 
+	w, err := writers.NewFileWriter(
+		dir,
+		writers.WithFS(fs),
+		writers.WithClock(clock),
+		writers.WithFilePrefix("test"),
+		writers.WithFileExt(".log"),
+		writers.WithFileRetention(fileRetention),
+		writers.WithFileMaxSizeInBytes(int64(maxFileSizeInBytes)),
+	)
 	if err != nil {
-		t.Fatal(err.Error())
+		t.Fatalf("new file writer failed, %v", err)
 	}
-}
-
-func TestFileTotalCountLimit(t *testing.T) {
-	var (
-		err        error
-		writeToDir = "./testdata/"
-	)
+	defer w.Close()
 
-	os.RemoveAll(writeToDir)
-	err = testFileTotalCountLimit(writeToDir)
-	os.RemoveAll(writeToDir)
+	for i := 0; i < 3; i++ {
+		if _, err = w.Write(contentToWrite); err != nil {
+			t.Fatalf("write failed, %v", err)
+		}
+	}
 
-	if err != nil {
-		t.Fatal(err.Error())
+	// check rotated file count
+	if names := fileNames(t, fs, dir); len(names) != 3 {
+		t.Fatalf("rotate error: file count mismatch, expected %d, got %v", 3, names)
 	}
+
+	// check file retention, the file in using is never cleared
+	clock.Advance(fileRetention + time.Second)
+	waitForFiles(t, fs, dir, []string{"test-20240101-0002.log"})
 }
 
-func TestFileRetention(t *testing.T) {
+func TestFileTotalCountLimit(t *testing.T) {
 	var (
-		err        error
-		writeToDir = "./testdata/"
+		dir                = "/logs"
+		contentToWrite     = []byte("Hello, this is a file writer test")
+		fileRetention      = time.Hour
+		maxFileSizeInBytes = 50
+		maxFileTotalCount  = 2
 	)
 
-	os.RemoveAll(writeToDir)
-	err = testFileRetention(writeToDir)
-	os.RemoveAll(writeToDir)
+	clock := alogtest.NewClock(testStartTime)
+	fs := writers.NewMemFS(clock)
 
+	w, err := writers.NewFileWriter(
+		dir,
+		writers.WithFS(fs),
+		writers.WithClock(clock),
+		writers.WithFilePrefix("test"),
+		writers.WithFileExt(".log"),
+		writers.WithFileRetention(fileRetention),
+		writers.WithFileMaxSizeInBytes(int64(maxFileSizeInBytes)),
+		writers.WithFileTotalCountLimit(maxFileTotalCount),
+	)
 	if err != nil {
-		t.Fatal(err.Error())
+		t.Fatalf("new file writer failed, %v", err)
+	}
+	defer w.Close()
+
+	for i := 0; i < 100; i++ {
+		if _, err = w.Write(contentToWrite); err != nil {
+			t.Fatalf("write failed, %v", err)
+		}
 	}
+
+	// check file total count limit
+	clock.Advance(time.Second)
+	waitForFiles(t, fs, dir, []string{"test-20240101-0098.log", "test-20240101-0099.log"})
 }
 
-func testFileWriter(dir string) error {
+func TestFileRetention(t *testing.T) {
 	var (
+		dir                = "/logs"
 		contentToWrite     = []byte("Hello, this is a file writer test")
-		fileRetention      = 5 * time.Second
+		fileRetention      = 2 * time.Second
 		maxFileSizeInBytes = 50
+		maxFileTotalCount  = 100000
 	)
 
+	clock := alogtest.NewClock(testStartTime)
+	fs := writers.NewMemFS(clock)
+
 	w, err := writers.NewFileWriter(
 		dir,
+		writers.WithFS(fs),
+		writers.WithClock(clock),
 		writers.WithFilePrefix("test"),
 		writers.WithFileExt(".log"),
 		writers.WithFileRetention(fileRetention),
 		writers.WithFileMaxSizeInBytes(int64(maxFileSizeInBytes)),
-		writers.WithLogWriter(os.Stderr),
+		writers.WithFileTotalCountLimit(maxFileTotalCount),
 	)
 	if err != nil {
-		return fmt.Errorf("new file writer failed, %w", err)
+		t.Fatalf("new file writer failed, %v", err)
 	}
 	defer w.Close()
 
-	for i := 0; i < 3; i++ {
+	for i := 0; i < 10; i++ {
 		if _, err = w.Write(contentToWrite); err != nil {
-			return fmt.Errorf("write failed, %w", err)
+			t.Fatalf("write failed, %v", err)
 		}
 	}
 
-	fileInfoList, err := ioutil.ReadDir(dir)
+	if names := fileNames(t, fs, dir); len(names) != 10 {
+		t.Fatalf("rotate error: file count mismatch, expected %d, got %v", 10, names)
+	}
+
+	// check file retention
+	clock.Advance(fileRetention + time.Second)
+	waitForFiles(t, fs, dir, []string{"test-20240101-0009.log"})
+}
+
+func TestFileWriter_DayRotate(t *testing.T) {
+	dir := "/logs"
+	clock := alogtest.NewClock(time.Date(2024, 1, 1, 23, 59, 59, 0, time.UTC))
+	fs := writers.NewMemFS(clock)
+
+	w, err := writers.NewFileWriter(
+		dir,
+		writers.WithFS(fs),
+		writers.WithClock(clock),
+		writers.WithFilePrefix("test"),
+		writers.WithFileExt(".log"),
+	)
 	if err != nil {
-		return fmt.Errorf("read dir failed, %w", err)
+		t.Fatalf("new file writer failed, %v", err)
 	}
+	defer w.Close()
 
-	// check rotated file count
-	if len(fileInfoList) != 3 {
-		return fmt.Errorf("rotate error: file count mismatch, expected %d, got %d", 3, len(fileInfoList))
+	if _, err = w.Write([]byte("before midnight\n")); err != nil {
+		t.Fatalf("write failed, %v", err)
 	}
 
-	// check file retention
-	<-time.After(fileRetention + 5*time.Second)
+	clock.Advance(time.Second)
+	waitForFiles(t, fs, dir, []string{"test-20240101-0000.log", "test-20240102-0000.log"})
 
-	fileInfoList, err = ioutil.ReadDir(dir)
-	if err != nil {
-		return fmt.Errorf("read dir failed, %w", err)
+	if _, err = w.Write([]byte("after midnight\n")); err != nil {
+		t.Fatalf("write failed, %v", err)
 	}
-	for _, info := range fileInfoList {
-		fmt.Println(info.Name())
+	b, err := fs.ReadFile(dir + "/test-20240102-0000.log")
+	if err != nil {
+		t.Fatalf("read file failed, %v", err)
 	}
-	if len(fileInfoList) != 0 {
-		return fmt.Errorf("retention error: file count mismatch, expected %d, got %d", 0, len(fileInfoList))
+	if string(b) != "after midnight\n" {
+		t.Fatalf("day rotate error: unexpected content %q", b)
 	}
-	return nil
 }
 
-func testFileTotalCountLimit(dir string) error {
-	var (
-		contentToWrite     = []byte("Hello, this is a file writer test")
-		fileRetention      = time.Hour
-		maxFileSizeInBytes = 50
-		maxFileTotalCount  = 2
-	)
+func TestFileWriter_FileMode(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("create dir failed, %v", err)
+	}
+	existing := filepath.Join(dir, "test-19700101-0000.log")
+	if err := os.WriteFile(existing, []byte("existing\n"), 0644); err != nil {
+		t.Fatalf("write file failed, %v", err)
+	}
 
 	w, err := writers.NewFileWriter(
 		dir,
 		writers.WithFilePrefix("test"),
 		writers.WithFileExt(".log"),
-		writers.WithFileRetention(fileRetention),
-		writers.WithFileMaxSizeInBytes(int64(maxFileSizeInBytes)),
-		writers.WithFileTotalCountLimit(maxFileTotalCount),
-		writers.WithLogWriter(os.Stderr),
+		writers.WithFileMaxSizeInBytes(50),
+		writers.WithFileMode(0600),
+		writers.WithDirMode(0700),
 	)
 	if err != nil {
-		return fmt.Errorf("new file writer failed, %w", err)
+		t.Fatalf("new file writer failed, %v", err)
 	}
 	defer w.Close()
 
-	for i := 0; i < 100; i++ {
-		if _, err = w.Write(contentToWrite); err != nil {
-			return fmt.Errorf("write failed, %w", err)
+	for i := 0; i < 3; i++ {
+		if _, err = w.Write([]byte("Hello, this is a file writer test")); err != nil {
+			t.Fatalf("write failed, %v", err)
 		}
 	}
 
-	// check file total count limit
-	<-time.After(3 * time.Second)
-
-	fileInfoList, err := ioutil.ReadDir(dir)
+	assertMode(t, writers.OSFS(), dir, 0700)
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return fmt.Errorf("read dir failed, %w", err)
+		t.Fatalf("read dir failed, %v", err)
 	}
-	for _, info := range fileInfoList {
-		fmt.Println(info.Name())
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 files, got %d", len(entries))
 	}
-	if len(fileInfoList) != maxFileTotalCount {
-		return fmt.Errorf("retention error: file total count mismatch, expected %d, got %d", maxFileTotalCount, len(fileInfoList))
+	for _, entry := range entries {
+		assertMode(t, writers.OSFS(), filepath.Join(dir, entry.Name()), 0600)
 	}
-	return nil
 }
-func testFileRetention(dir string) error {
-	var (
-		contentToWrite     = []byte("Hello, this is a file writer test")
-		fileRetention      = 2 * time.Second
-		maxFileSizeInBytes = 50
-		maxFileTotalCount  = 100000
-	)
+
+func TestFileWriter_FileOwner(t *testing.T) {
+	dir := "/logs"
+	clock := alogtest.NewClock(testStartTime)
+	fs := writers.NewMemFS(clock)
 
 	w, err := writers.NewFileWriter(
 		dir,
+		writers.WithFS(fs),
+		writers.WithClock(clock),
 		writers.WithFilePrefix("test"),
 		writers.WithFileExt(".log"),
-		writers.WithFileRetention(fileRetention),
-		writers.WithFileMaxSizeInBytes(int64(maxFileSizeInBytes)),
-		writers.WithFileTotalCountLimit(maxFileTotalCount),
-		writers.WithLogWriter(os.Stderr),
+		writers.WithFileMaxSizeInBytes(50),
+		writers.WithFileMode(0640),
+		writers.WithFileOwner(-1, 2000),
 	)
 	if err != nil {
-		return fmt.Errorf("new file writer failed, %w", err)
+		t.Fatalf("new file writer failed, %v", err)
 	}
 	defer w.Close()
 
-	for i := 0; i < 10; i++ {
-		if _, err = w.Write(contentToWrite); err != nil {
-			return fmt.Errorf("write failed, %w", err)
+	for i := 0; i < 2; i++ {
+		if _, err = w.Write([]byte("Hello, this is a file writer test")); err != nil {
+			t.Fatalf("write failed, %v", err)
 		}
 	}
 
-	// check file total count limit
-	<-time.After(3 * time.Second)
+	for _, name := range append(fileNames(t, fs, dir), "") {
+		path := filepath.Join(dir, name)
+		if uid, gid, err := fs.Owner(path); err != nil || uid != 0 || gid != 2000 {
+			t.Fatalf("bad owner of `%s`, uid %d, gid %d, err %v", path, uid, gid, err)
+		}
+		if name == "" {
+			continue
+		}
+		assertMode(t, fs, path, 0640)
+	}
+}
+
+func assertMode(t *testing.T, fs writers.FS, path string, expected os.FileMode) {
+	t.Helper()
+
+	info, err := fs.Stat(path)
+	if err != nil {
+		t.Fatalf("stat `%s` failed, %v", path, err)
+	}
+	if info.Mode().Perm() != expected {
+		t.Fatalf("mode of `%s` mismatch, expected %v, got %v", path, expected, info.Mode().Perm())
+	}
+}
 
-	fileInfoList, err := ioutil.ReadDir(dir)
+func fileNames(t *testing.T, fs writers.FS, dir string) []string {
+	t.Helper()
+
+	entries, err := fs.ReadDir(dir)
 	if err != nil {
-		return fmt.Errorf("read dir failed, %w", err)
+		t.Fatalf("read dir failed, %v", err)
 	}
-	for _, info := range fileInfoList {
-		fmt.Println(info.Name())
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
 	}
-	if len(fileInfoList) != 1 {
-		return fmt.Errorf("retention error: file total count mismatch, expected %d, got %d", 1, len(fileInfoList))
+	return names
+}
+
+// waitForFiles waits for the automation worker to handle the latest tick of the fake clock.
+func waitForFiles(t *testing.T, fs writers.FS, dir string, expected []string) {
+	t.Helper()
+
+	var names []string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if names = fileNames(t, fs, dir); fmt.Sprint(names) == fmt.Sprint(expected) {
+			return
+		}
 	}
-	return nil
+	t.Fatalf("files mismatch, expected [%s], got [%s]", strings.Join(expected, " "), strings.Join(names, " "))
 }
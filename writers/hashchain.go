@@ -0,0 +1,399 @@
+package writers
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Hash-chained segment layout:
+//
+//	#alog-chain v1 <hex hash of the previous segment's last entry>\n
+//	<hex hash> <entry length> <entry>
+//	...
+//
+// The hash of an entry is SHA-256(previous hash || entry), or HMAC-SHA256 with the chain
+// key if the chain is keyed, whose header starts with "#alog-chain v1-hmac" instead.
+// The first segment ever written chains to a zero hash.
+const (
+	chainHeaderPrefix      = "#alog-chain v1 "
+	chainKeyedHeaderPrefix = "#alog-chain v1-hmac "
+)
+
+type chainCodec struct {
+	key    []byte
+	anchor func(head string)
+	head   [sha256.Size]byte
+	// pending is the head after the entry framed last, it is committed once the entry is written
+	pending [sha256.Size]byte
+}
+
+func newChainCodec(key []byte, anchor func(head string)) *chainCodec {
+	return &chainCodec{key: key, anchor: anchor}
+}
+
+func (c *chainCodec) header() ([]byte, error) {
+	prefix := chainHeaderPrefix
+	if c.key != nil {
+		prefix = chainKeyedHeaderPrefix
+	}
+	return []byte(prefix + hex.EncodeToString(c.head[:]) + "\n"), nil
+}
+
+func (c *chainCodec) commitHeader() {}
+
+func (c *chainCodec) size(n int) int {
+	return hex.EncodedLen(sha256.Size) + 1 + len(strconv.Itoa(n)) + 1 + n
+}
+
+func (c *chainCodec) encode(b []byte) ([]byte, error) {
+	c.pending = chainHash(c.key, c.head, b)
+
+	record := make([]byte, 0, c.size(len(b)))
+	record = hex.AppendEncode(record, c.pending[:])
+	record = append(record, ' ')
+	record = strconv.AppendInt(record, int64(len(b)), 10)
+	record = append(record, ' ')
+	return append(record, b...), nil
+}
+
+func (c *chainCodec) commit() {
+	c.head = c.pending
+	if c.anchor != nil {
+		c.anchor(hex.EncodeToString(c.head[:]))
+	}
+}
+
+// resume continues the chain from the last complete entry of r. A broken or
+// truncated tail is left to the verifier to report.
+func (c *chainCodec) resume(r io.Reader) error {
+	cr := newChainReader(r)
+	// restarting the chain from zero would break the verification of all the later segments
+	head, keyed, err := cr.readHeader()
+	if err != nil {
+		return err
+	}
+	if keyed != (c.key != nil) {
+		return errors.New("the chain of the latest segment is keyed differently")
+	}
+	for {
+		hash, _, err := cr.readRecord()
+		if err != nil {
+			break
+		}
+		head = hash
+	}
+	c.head = head
+	return nil
+}
+
+func chainHash(key []byte, prev [sha256.Size]byte, entry []byte) [sha256.Size]byte {
+	h := sha256.New()
+	if key != nil {
+		h = hmac.New(sha256.New, key)
+	}
+	h.Write(prev[:])
+	h.Write(entry)
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+type chainReader struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+func newChainReader(r io.Reader) *chainReader {
+	return &chainReader{r: bufio.NewReader(r)}
+}
+
+// readHeader reads the hash the segment chains to, and whether the chain is keyed.
+func (cr *chainReader) readHeader() ([sha256.Size]byte, bool, error) {
+	var prev [sha256.Size]byte
+
+	line, err := cr.r.ReadString('\n')
+	cr.offset += int64(len(line))
+	if err != nil {
+		return prev, false, fmt.Errorf("read header failed, %w", err)
+	}
+	prefix, keyed := chainHeaderPrefix, false
+	if strings.HasPrefix(line, chainKeyedHeaderPrefix) {
+		prefix, keyed = chainKeyedHeaderPrefix, true
+	} else if !strings.HasPrefix(line, chainHeaderPrefix) {
+		return prev, false, errors.New("not a hash-chained segment")
+	}
+	if err := decodeHash(&prev, strings.TrimSuffix(strings.TrimPrefix(line, prefix), "\n")); err != nil {
+		return prev, keyed, fmt.Errorf("bad header hash, %w", err)
+	}
+	return prev, keyed, nil
+}
+
+// readRecord reads an entry and the hash it claims. It returns io.EOF only if
+// the segment ends right before the record.
+func (cr *chainReader) readRecord() ([sha256.Size]byte, []byte, error) {
+	var hash [sha256.Size]byte
+
+	hashHex, err := cr.readField()
+	if err == io.EOF && hashHex == "" {
+		return hash, nil, io.EOF
+	}
+	if err != nil {
+		return hash, nil, fmt.Errorf("truncated record, %w", err)
+	}
+	if err := decodeHash(&hash, hashHex); err != nil {
+		return hash, nil, fmt.Errorf("bad record hash, %w", err)
+	}
+	lenStr, err := cr.readField()
+	if err != nil {
+		return hash, nil, fmt.Errorf("truncated record, %w", err)
+	}
+	n, err := strconv.Atoi(lenStr)
+	if err != nil || n < 0 {
+		return hash, nil, fmt.Errorf("bad record length `%s`", lenStr)
+	}
+	entry := make([]byte, n)
+	read, err := io.ReadFull(cr.r, entry)
+	cr.offset += int64(read)
+	if err != nil {
+		return hash, nil, fmt.Errorf("truncated record, %w", err)
+	}
+	return hash, entry, nil
+}
+
+func (cr *chainReader) readField() (string, error) {
+	field, err := cr.r.ReadString(' ')
+	cr.offset += int64(len(field))
+	if err != nil {
+		return field, err
+	}
+	return strings.TrimSuffix(field, " "), nil
+}
+
+func decodeHash(dst *[sha256.Size]byte, s string) error {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	if len(b) != sha256.Size {
+		return fmt.Errorf("hash length %d mismatch", len(b))
+	}
+	copy(dst[:], b)
+	return nil
+}
+
+// ChainBreak describes the first broken link found in a hash chain.
+type ChainBreak struct {
+	// File is the segment holding the broken link.
+	File string
+	// Record is the index of the broken entry in the segment, or -1 if the segment header is broken.
+	Record int
+	// Offset is the byte offset in the segment where the broken link starts.
+	Offset int64
+	// Reason tells why the link is considered broken.
+	Reason string
+}
+
+func (b *ChainBreak) Error() string {
+	if b.Record < 0 {
+		return fmt.Sprintf("hash chain broken at `%s` header: %s", b.File, b.Reason)
+	}
+	return fmt.Sprintf("hash chain broken at `%s` record %d (offset %d): %s", b.File, b.Record, b.Offset, b.Reason)
+}
+
+// ChainReport is the result of a hash chain verification.
+type ChainReport struct {
+	// Files is the count of segments verified.
+	Files int
+	// Records is the count of entries verified before the chain broke, if it did.
+	Records int
+	// Head is the hex hash of the last verified entry.
+	Head string
+	// Break is the first broken link, nil if the chain is intact.
+	Break *ChainBreak
+}
+
+// ChainVerifyOption configures the verification of a hash chain.
+type ChainVerifyOption func(v *chainVerifier)
+
+type chainVerifier struct {
+	key   []byte
+	start string
+	head  string
+}
+
+// WithChainKey verifies a chain keyed by WithHashChainKey. Unkeyed segments are reported broken,
+// so they can't be forged in place of keyed ones.
+func WithChainKey(key []byte) ChainVerifyOption {
+	return func(v *chainVerifier) {
+		v.key = key
+	}
+}
+
+// WithChainStart requires the first segment to chain to the hex hash, e.g. the zero hash if the
+// oldest segment must be kept, or the head anchored before older segments were cleared by retention.
+// Without it, deleting the leading segments can't be detected.
+func WithChainStart(hash string) ChainVerifyOption {
+	return func(v *chainVerifier) {
+		v.start = hash
+	}
+}
+
+// WithChainHead requires the chain to reach the hex hash, e.g. the last head reported to the anchor
+// of WithHashChainAnchor. Without it, truncating the tail of the chain can't be detected.
+func WithChainHead(hash string) ChainVerifyOption {
+	return func(v *chainVerifier) {
+		v.head = hash
+	}
+}
+
+// VerifyHashChainDir verifies the hash-chained segments in dir in filename order.
+// filePrefix and fileExt select segments like WithFilePrefix and WithFileExt do.
+//
+// Without WithChainStart, the first segment found is accepted as the start of the chain,
+// because earlier segments may have been cleared by retention.
+func VerifyHashChainDir(dir, filePrefix, fileExt string, opts ...ChainVerifyOption) (*ChainReport, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("scan dir failed, %w", err)
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if (filePrefix != "" && !strings.HasPrefix(entry.Name(), filePrefix)) ||
+			(fileExt != "" && !strings.HasSuffix(entry.Name(), fileExt)) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(files)
+	return VerifyHashChain(files, opts...)
+}
+
+// VerifyHashChainFiles verifies hash-chained segments in the given order and stops at the first broken link.
+func VerifyHashChainFiles(files ...string) (*ChainReport, error) {
+	return VerifyHashChain(files)
+}
+
+// VerifyHashChain verifies hash-chained segments in the given order and stops at the first broken link.
+//
+// An unkeyed chain only detects accidental damage: whoever can edit the segments can recompute
+// the hashes after an edit. A keyed chain detects edits and deletions inside the chain, as long as
+// the key is kept away from the segments; deleting leading segments or truncating the tail is only
+// detected with WithChainStart and WithChainHead, whose hashes must be stored out of reach too.
+// Entries written after the anchored head can still be dropped unnoticed.
+func VerifyHashChain(files []string, opts ...ChainVerifyOption) (*ChainReport, error) {
+	v := &chainVerifier{}
+	for _, opt := range opts {
+		opt(v)
+	}
+	var start, anchored *[sha256.Size]byte
+	if v.start != "" {
+		start = new([sha256.Size]byte)
+		if err := decodeHash(start, v.start); err != nil {
+			return nil, fmt.Errorf("bad chain start, %w", err)
+		}
+	}
+	if v.head != "" {
+		anchored = new([sha256.Size]byte)
+		if err := decodeHash(anchored, v.head); err != nil {
+			return nil, fmt.Errorf("bad chain head, %w", err)
+		}
+	}
+
+	report := &ChainReport{}
+	var head [sha256.Size]byte
+	reached := false
+	for idx, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("open `%s` failed, %w", file, err)
+		}
+		seg := &segmentVerifier{key: v.key, anchored: anchored, head: &head, report: report}
+		if idx == 0 {
+			seg.start = start
+		} else {
+			seg.chained = true
+		}
+		report.Break = seg.verify(f, file)
+		f.Close()
+		report.Files++
+		report.Head = hex.EncodeToString(head[:])
+		reached = reached || seg.reached
+		if report.Break != nil {
+			return report, nil
+		}
+		if anchored != nil && idx == len(files)-1 && !reached {
+			report.Break = &ChainBreak{File: file, Record: seg.records, Offset: seg.offset, Reason: "chain does not reach the anchored head, the tail is truncated"}
+		}
+	}
+	if anchored != nil && len(files) == 0 {
+		report.Break = &ChainBreak{Record: -1, Reason: "no segment found to reach the anchored head"}
+	}
+	return report, nil
+}
+
+// segmentVerifier verifies a segment, continuing from head.
+type segmentVerifier struct {
+	key []byte
+	// start is the hash the segment must chain to, or chained requires it to chain to head
+	start    *[sha256.Size]byte
+	chained  bool
+	anchored *[sha256.Size]byte
+	head     *[sha256.Size]byte
+	report   *ChainReport
+
+	reached bool
+	records int
+	offset  int64
+}
+
+func (v *segmentVerifier) verify(r io.Reader, file string) *ChainBreak {
+	cr := newChainReader(r)
+
+	prev, keyed, err := cr.readHeader()
+	if err != nil {
+		return &ChainBreak{File: file, Record: -1, Reason: err.Error()}
+	}
+	switch {
+	case keyed && v.key == nil:
+		return &ChainBreak{File: file, Record: -1, Reason: "segment is keyed, the chain key is required"}
+	case !keyed && v.key != nil:
+		return &ChainBreak{File: file, Record: -1, Reason: "segment is not keyed"}
+	case v.chained && prev != *v.head:
+		return &ChainBreak{File: file, Record: -1, Reason: "header does not chain to the previous segment"}
+	case v.start != nil && prev != *v.start:
+		return &ChainBreak{File: file, Record: -1, Reason: "header does not chain to the expected start"}
+	}
+	*v.head = prev
+	v.reached = v.anchored != nil && prev == *v.anchored
+
+	for idx := 0; ; idx++ {
+		v.records, v.offset = idx, cr.offset
+		hash, entry, err := cr.readRecord()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return &ChainBreak{File: file, Record: idx, Offset: v.offset, Reason: err.Error()}
+		}
+		if expected := chainHash(v.key, *v.head, entry); expected != hash {
+			return &ChainBreak{File: file, Record: idx, Offset: v.offset, Reason: "entry hash mismatch"}
+		}
+		*v.head = hash
+		v.reached = v.reached || (v.anchored != nil && hash == *v.anchored)
+		v.report.Records++
+	}
+}
@@ -0,0 +1,255 @@
+package writers_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/csh0101/alog/writers"
+)
+
+func TestFileWriter_HashChain(t *testing.T) {
+	dir := t.TempDir()
+	writeChainedEntries(t, dir, 0, 6)
+
+	// the chain carries across restarts
+	writeChainedEntries(t, dir, 6, 2)
+
+	files := segmentFiles(t, dir)
+	if len(files) < 3 {
+		t.Fatalf("expected rotated segments, got %d", len(files))
+	}
+
+	report, err := writers.VerifyHashChainDir(dir, "test", ".log")
+	if err != nil {
+		t.Fatalf("verify failed, %v", err)
+	}
+	if report.Break != nil {
+		t.Fatalf("intact chain reported broken, %v", report.Break)
+	}
+	if report.Records != 8 || report.Files != len(files) {
+		t.Fatalf("expected 8 entries in %d files, got %d in %d", len(files), report.Records, report.Files)
+	}
+
+	// segments cleared by retention are not reported
+	report, err = writers.VerifyHashChainFiles(files[1:]...)
+	if err != nil {
+		t.Fatalf("verify failed, %v", err)
+	}
+	if report.Break != nil {
+		t.Fatalf("chain without the oldest segment reported broken, %v", report.Break)
+	}
+}
+
+func TestVerifyHashChain_Tampered(t *testing.T) {
+	dir := t.TempDir()
+	writeChainedEntries(t, dir, 0, 6)
+	files := segmentFiles(t, dir)
+
+	// edit an entry
+	b, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("read file failed, %v", err)
+	}
+	if err := os.WriteFile(files[0], bytes.Replace(b, []byte(`"index":1`), []byte(`"index":7`), 1), 0644); err != nil {
+		t.Fatalf("write file failed, %v", err)
+	}
+	report, err := writers.VerifyHashChainDir(dir, "test", ".log")
+	if err != nil {
+		t.Fatalf("verify failed, %v", err)
+	}
+	if report.Break == nil || report.Break.File != files[0] || report.Break.Record != 1 {
+		t.Fatalf("expected break at record 1 of the first segment, got %v", report.Break)
+	}
+	if err := os.WriteFile(files[0], b, 0644); err != nil {
+		t.Fatalf("write file failed, %v", err)
+	}
+
+	// delete a segment in the middle
+	if err := os.Remove(files[1]); err != nil {
+		t.Fatalf("remove file failed, %v", err)
+	}
+	report, err = writers.VerifyHashChainDir(dir, "test", ".log")
+	if err != nil {
+		t.Fatalf("verify failed, %v", err)
+	}
+	if report.Break == nil || report.Break.File != files[2] || report.Break.Record != -1 {
+		t.Fatalf("expected break at the header of the third segment, got %v", report.Break)
+	}
+}
+
+func TestFileWriter_HashChainWithEncryption(t *testing.T) {
+	kp, err := writers.NewStaticKeyProvider("k1", map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+	})
+	if err != nil {
+		t.Fatalf("new key provider failed, %v", err)
+	}
+	if _, err := writers.NewFileWriter(t.TempDir(), writers.WithHashChain(), writers.WithEncryption(kp)); err == nil {
+		t.Fatal("hash chain and encryption must not be enabled together")
+	}
+}
+
+func TestFileWriter_HashChainWriteFailure(t *testing.T) {
+	fs := &failFS{MemFS: writers.NewMemFS(nil)}
+	w, err := writers.NewFileWriter("/logs", writers.WithFS(fs), writers.WithHashChain())
+	if err != nil {
+		t.Fatalf("new file writer failed, %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if i == 1 {
+			fs.failNext(1, false)
+		}
+		_, err := w.Write([]byte(fmt.Sprintf("{\"index\":%d}\n", i)))
+		if (err != nil) != (i == 1) {
+			t.Fatalf("write %d, unexpected error %v", i, err)
+		}
+	}
+	w.Close()
+
+	// a failed write must not break the chain of the later entries
+	dir := t.TempDir()
+	for _, name := range fileNames(t, fs, "/logs") {
+		b, err := fs.ReadFile("/logs/" + name)
+		if err != nil {
+			t.Fatalf("read file failed, %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			t.Fatalf("write file failed, %v", err)
+		}
+	}
+	report, err := writers.VerifyHashChainDir(dir, "", "")
+	if err != nil {
+		t.Fatalf("verify failed, %v", err)
+	}
+	if report.Break != nil || report.Records != 2 {
+		t.Fatalf("expected 2 intact entries, got %d, %v", report.Records, report.Break)
+	}
+}
+
+func TestVerifyHashChain_Keyed(t *testing.T) {
+	dir := t.TempDir()
+	key := []byte("chain key")
+	var head string
+	w, err := writers.NewFileWriter(dir,
+		writers.WithFilePrefix("test"),
+		writers.WithFileExt(".log"),
+		writers.WithFileMaxSizeInBytes(300),
+		writers.WithHashChainKey(key),
+		writers.WithHashChainAnchor(func(h string) { head = h }),
+	)
+	if err != nil {
+		t.Fatalf("new file writer failed, %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		if _, err := w.Write([]byte(fmt.Sprintf("{\"msg\":\"hello hash chain\",\"index\":%d}\n", i))); err != nil {
+			t.Fatalf("write failed, %v", err)
+		}
+	}
+	w.Close()
+	files := segmentFiles(t, dir)
+	zero := strings.Repeat("0", 64)
+
+	verify := func(files []string, opts ...writers.ChainVerifyOption) *writers.ChainReport {
+		t.Helper()
+		report, err := writers.VerifyHashChain(files, opts...)
+		if err != nil {
+			t.Fatalf("verify failed, %v", err)
+		}
+		return report
+	}
+	if report := verify(files, writers.WithChainKey(key), writers.WithChainStart(zero), writers.WithChainHead(head)); report.Break != nil || report.Records != 6 || report.Head != head {
+		t.Fatalf("intact chain reported broken, %+v", report)
+	}
+	if report := verify(files); report.Break == nil || report.Break.Record != -1 {
+		t.Fatalf("keyed chain must not be verified without the key, %+v", report)
+	}
+	if report := verify(files, writers.WithChainKey([]byte("other"))); report.Break == nil || report.Break.Record != 0 {
+		t.Fatalf("keyed chain must not be verified with another key, %+v", report)
+	}
+	// leading segments deleted
+	if report := verify(files[1:], writers.WithChainKey(key), writers.WithChainStart(zero)); report.Break == nil || report.Break.File != files[1] {
+		t.Fatalf("deleted leading segment is not detected, %+v", report)
+	}
+	// tail truncated
+	if report := verify(files[:len(files)-1], writers.WithChainKey(key), writers.WithChainHead(head)); report.Break == nil || report.Break.File != files[len(files)-2] {
+		t.Fatalf("truncated tail is not detected, %+v", report)
+	}
+
+	// rewritten as an unkeyed chain
+	b, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("read file failed, %v", err)
+	}
+	if err := os.WriteFile(files[0], bytes.Replace(b, []byte("v1-hmac "), []byte("v1 "), 1), 0644); err != nil {
+		t.Fatalf("write file failed, %v", err)
+	}
+	if report := verify(files, writers.WithChainKey(key)); report.Break == nil || report.Break.File != files[0] {
+		t.Fatalf("unkeyed segment is not detected, %+v", report)
+	}
+}
+
+func TestFileWriter_HashChainResumeFailure(t *testing.T) {
+	dir := t.TempDir()
+	writeChainedEntries(t, dir, 0, 2)
+	files := segmentFiles(t, dir)
+	latest := files[len(files)-1]
+
+	// the head can't be resumed from a damaged header, the chain must not restart silently
+	b, err := os.ReadFile(latest)
+	if err != nil {
+		t.Fatalf("read file failed, %v", err)
+	}
+	if err := os.WriteFile(latest, append([]byte("garbage"), b...), 0644); err != nil {
+		t.Fatalf("write file failed, %v", err)
+	}
+	open := func(opts ...writers.FileWriterOption) error {
+		w, err := writers.NewFileWriter(dir, append([]writers.FileWriterOption{
+			writers.WithFilePrefix("test"),
+			writers.WithFileExt(".log"),
+		}, opts...)...)
+		if err == nil {
+			w.Close()
+		}
+		return err
+	}
+	if err := open(writers.WithHashChain()); err == nil || !strings.Contains(err.Error(), filepath.Base(latest)) {
+		t.Fatalf("resume from a damaged header must fail, got %v", err)
+	}
+
+	// nor from a chain keyed differently
+	if err := os.WriteFile(latest, b, 0644); err != nil {
+		t.Fatalf("write file failed, %v", err)
+	}
+	if err := open(writers.WithHashChainKey([]byte("key"))); err == nil {
+		t.Fatal("resume a keyed chain from an unkeyed one must fail")
+	}
+	if err := open(writers.WithHashChain()); err != nil {
+		t.Fatalf("resume failed, %v", err)
+	}
+}
+
+func writeChainedEntries(t *testing.T, dir string, from, count int) {
+	t.Helper()
+
+	w, err := writers.NewFileWriter(dir,
+		writers.WithFilePrefix("test"),
+		writers.WithFileExt(".log"),
+		writers.WithFileMaxSizeInBytes(300),
+		writers.WithHashChain(),
+	)
+	if err != nil {
+		t.Fatalf("new file writer failed, %v", err)
+	}
+	defer w.Close()
+
+	for i := from; i < from+count; i++ {
+		line := fmt.Sprintf("{\"msg\":\"hello hash chain\",\"index\":%d}\n", i)
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("write failed, %v", err)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package writers
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ FS = &MemFS{}
+
+// MemFS is an in-memory FS. Modification times of files are taken from its clock,
+// which makes it suitable to test rotation and retention against a fake clock.
+type MemFS struct {
+	clock Clock
+
+	mutex sync.RWMutex
+	dirs  map[string]*memNode
+	files map[string]*memNode
+}
+
+type memNode struct {
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+	owner   memOwner
+}
+
+// memOwner is returned by Sys() of the file info of MemFS.
+type memOwner struct {
+	uid int
+	gid int
+}
+
+// NewMemFS new an empty in-memory filesystem. The system clock is used if clock is nil.
+func NewMemFS(clock Clock) *MemFS {
+	if clock == nil {
+		clock = SystemClock()
+	}
+	return &MemFS{
+		clock: clock,
+		dirs: map[string]*memNode{
+			string(filepath.Separator): {mode: os.ModeDir | os.ModePerm, modTime: clock.Now()},
+		},
+		files: make(map[string]*memNode),
+	}
+}
+
+func (m *MemFS) MkdirAll(path string, perm os.FileMode) error {
+	path = memPath(path)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for p := path; ; p = filepath.Dir(p) {
+		if _, ok := m.files[p]; ok {
+			return &os.PathError{Op: "mkdir", Path: p, Err: fs.ErrExist}
+		}
+		if _, ok := m.dirs[p]; !ok {
+			m.dirs[p] = &memNode{mode: os.ModeDir | perm.Perm(), modTime: m.clock.Now()}
+		}
+		if p == filepath.Dir(p) {
+			return nil
+		}
+	}
+}
+
+func (m *MemFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	path := memPath(name)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.dirs[filepath.Dir(path)]; !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if _, ok := m.dirs[path]; ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+	node, ok := m.files[path]
+	switch {
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case !ok:
+		node = &memNode{mode: perm.Perm(), modTime: m.clock.Now()}
+		m.files[path] = node
+	case flag&os.O_TRUNC != 0:
+		node.data = nil
+		node.modTime = m.clock.Now()
+	}
+
+	return &memFile{fs: m, node: node, name: name, flag: flag}, nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]os.DirEntry, error) {
+	path := memPath(name)
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if _, ok := m.dirs[path]; !ok {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	var entries []os.DirEntry
+	for p, node := range m.dirs {
+		if p != path && filepath.Dir(p) == path {
+			entries = append(entries, fs.FileInfoToDirEntry(node.info(filepath.Base(p))))
+		}
+	}
+	for p, node := range m.files {
+		if filepath.Dir(p) == path {
+			entries = append(entries, fs.FileInfoToDirEntry(node.info(filepath.Base(p))))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	path := memPath(name)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.files[path]; ok {
+		delete(m.files, path)
+		return nil
+	}
+	if _, ok := m.dirs[path]; ok {
+		for p := range m.files {
+			if strings.HasPrefix(p, path+string(filepath.Separator)) {
+				return &os.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+			}
+		}
+		delete(m.dirs, path)
+		return nil
+	}
+	return &os.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+}
+
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	node, err := m.node("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return node.info(filepath.Base(memPath(name))), nil
+}
+
+func (m *MemFS) Chmod(name string, mode os.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	node, err := m.node("chmod", name)
+	if err != nil {
+		return err
+	}
+	node.mode = node.mode&os.ModeType | mode.Perm()
+	return nil
+}
+
+func (m *MemFS) Chown(name string, uid, gid int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	node, err := m.node("chown", name)
+	if err != nil {
+		return err
+	}
+	if uid >= 0 {
+		node.owner.uid = uid
+	}
+	if gid >= 0 {
+		node.owner.gid = gid
+	}
+	return nil
+}
+
+// Owner returns the owner of the named file or directory.
+func (m *MemFS) Owner(name string) (uid, gid int, err error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	node, err := m.node("owner", name)
+	if err != nil {
+		return 0, 0, err
+	}
+	return node.owner.uid, node.owner.gid, nil
+}
+
+func (m *MemFS) node(op, name string) (*memNode, error) {
+	path := memPath(name)
+	if node, ok := m.files[path]; ok {
+		return node, nil
+	}
+	if node, ok := m.dirs[path]; ok {
+		return node, nil
+	}
+	return nil, &os.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadFile returns the content of the named file.
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	node, ok := m.files[memPath(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), node.data...), nil
+}
+
+func memPath(name string) string {
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return filepath.Clean(name)
+}
+
+func (n *memNode) info(name string) *memFileInfo {
+	return &memFileInfo{
+		name:    name,
+		size:    int64(len(n.data)),
+		mode:    n.mode,
+		modTime: n.modTime,
+		owner:   n.owner,
+	}
+}
+
+type memFile struct {
+	fs     *MemFS
+	node   *memNode
+	name   string
+	flag   int
+	offset int64
+	closed bool
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+func (f *memFile) Read(b []byte) (int, error) {
+	f.fs.mutex.RLock()
+	defer f.fs.mutex.RUnlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.offset >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.node.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memFile) Write(b []byte) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: errors.New("bad file descriptor")}
+	}
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	if end := f.offset + int64(len(b)); end > int64(len(f.node.data)) {
+		f.node.data = append(f.node.data, make([]byte, end-int64(len(f.node.data)))...)
+	}
+	n := copy(f.node.data[f.offset:], b)
+	f.offset += int64(n)
+	f.node.modTime = f.fs.clock.Now()
+	return n, nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.fs.mutex.RLock()
+	defer f.fs.mutex.RUnlock()
+
+	return f.node.info(filepath.Base(f.name)), nil
+}
+
+func (f *memFile) Sync() error {
+	return nil
+}
+
+func (f *memFile) Close() error {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+	f.closed = true
+	return nil
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	owner   memOwner
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return i.size }
+func (i *memFileInfo) Mode() os.FileMode  { return i.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.modTime }
+func (i *memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memFileInfo) Sys() interface{}   { return i.owner }
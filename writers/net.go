@@ -0,0 +1,134 @@
+package writers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+var _ io.WriteCloser = &NetWriter{}
+
+// NetWriter writes logs to a network sink, e.g. a TCP or UDP log collector.
+// Every Write is sent as a whole, which is one datagram for packet-oriented networks.
+// The connection is dialed lazily and redialed once if a write fails.
+type NetWriter struct {
+	logger       *log.Logger
+	network      string
+	address      string
+	dialTimeout  time.Duration
+	writeTimeout time.Duration
+
+	mutex  sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// NewNetWriter new a writer sending logs to address. Supported networks are
+// tcp, tcp4, tcp6, udp, udp4, udp6, unix and unixgram.
+//
+//   - default dial timeout  :  5s
+//   - default write timeout :  5s
+func NewNetWriter(network, address string, opts ...NetWriterOption) (*NetWriter, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "unix", "unixgram":
+	default:
+		return nil, fmt.Errorf("unsupported network `%s`", network)
+	}
+	if address == "" {
+		return nil, errors.New("params address is required")
+	}
+
+	w := &NetWriter{
+		logger:       log.New(io.Discard, "", log.LstdFlags),
+		network:      network,
+		address:      address,
+		dialTimeout:  5 * time.Second,
+		writeTimeout: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w, nil
+}
+
+func (w *NetWriter) Write(b []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	var err error
+	written := 0
+	for retry := 0; retry < 2; retry++ {
+		if w.conn == nil {
+			if w.conn, err = net.DialTimeout(w.network, w.address, w.dialTimeout); err != nil {
+				w.conn = nil
+				err = fmt.Errorf("dial %s `%s` failed, %w", w.network, w.address, err)
+				continue
+			}
+		}
+		if w.writeTimeout > 0 {
+			_ = w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+		}
+		var n int
+		n, err = w.conn.Write(b[written:])
+		// only the rest is resent after a partial write, so no byte is duplicated on the stream
+		if written += n; err == nil {
+			return written, nil
+		}
+		w.logger.Printf("[W] write to %s `%s` failed, redial, %v", w.network, w.address, err)
+		w.conn.Close()
+		w.conn = nil
+	}
+	return written, err
+}
+
+func (w *NetWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.conn != nil {
+		return w.conn.Close()
+	}
+	return nil
+}
+
+type NetWriterOption func(w *NetWriter)
+
+// WithNetDialTimeout sets the timeout of dialing.
+func WithNetDialTimeout(v time.Duration) NetWriterOption {
+	return func(w *NetWriter) {
+		if v > 0 {
+			w.dialTimeout = v
+		}
+	}
+}
+
+// WithNetWriteTimeout sets the timeout of a write, 0 means no timeout.
+func WithNetWriteTimeout(v time.Duration) NetWriterOption {
+	return func(w *NetWriter) {
+		if v >= 0 {
+			w.writeTimeout = v
+		}
+	}
+}
+
+// WithNetLogWriter sets where the writer reports connection failures.
+func WithNetLogWriter(writer io.Writer) NetWriterOption {
+	return func(w *NetWriter) {
+		if writer != nil {
+			w.logger.SetOutput(writer)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package writers_test
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/csh0101/alog/writers"
+)
+
+func TestNetWriter_TCPRedial(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed, %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 10)
+	conns := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go func() {
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					received <- scanner.Text()
+				}
+			}()
+		}
+	}()
+
+	w, err := writers.NewNetWriter("tcp", listener.Addr().String(), writers.WithNetWriteTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("new net writer failed, %v", err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte("first\n")); err != nil {
+		t.Fatalf("write failed, %v", err)
+	}
+	expectReceived(t, received, "first")
+
+	// the collector restarts, the writer redials
+	(<-conns).Close()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, err := w.Write([]byte("second\n")); err != nil {
+			t.Fatalf("write failed, %v", err)
+		}
+		if len(conns) > 0 {
+			break
+		}
+	}
+	expectReceived(t, received, "second")
+}
+
+func TestNetWriter_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed, %v", err)
+	}
+	defer conn.Close()
+
+	w, err := writers.NewNetWriter("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("new net writer failed, %v", err)
+	}
+	if _, err := w.Write([]byte("datagram\n")); err != nil {
+		t.Fatalf("write failed, %v", err)
+	}
+	w.Close()
+	if _, err := w.Write([]byte("closed\n")); err == nil {
+		t.Fatal("write after close must fail")
+	}
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil || string(buf[:n]) != "datagram\n" {
+		t.Fatalf("unexpected datagram %q, %v", buf[:n], err)
+	}
+
+	if _, err := writers.NewNetWriter("http", "127.0.0.1:80"); err == nil {
+		t.Fatal("unsupported network must be rejected")
+	}
+}
+
+func expectReceived(t *testing.T, received chan string, expected string) {
+	t.Helper()
+
+	for {
+		select {
+		case line := <-received:
+			if line == expected {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("`%s` is not received", expected)
+		}
+	}
+}
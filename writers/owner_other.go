@@ -0,0 +1,12 @@
+//go:build !unix
+
+package writers
+
+import "os"
+
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	if sys, isMem := info.Sys().(memOwner); isMem {
+		return sys.uid, sys.gid, true
+	}
+	return 0, 0, false
+}
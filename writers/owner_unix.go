@@ -0,0 +1,18 @@
+//go:build unix
+
+package writers
+
+import (
+	"os"
+	"syscall"
+)
+
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	switch sys := info.Sys().(type) {
+	case memOwner:
+		return sys.uid, sys.gid, true
+	case *syscall.Stat_t:
+		return int(sys.Uid), int(sys.Gid), true
+	}
+	return 0, 0, false
+}
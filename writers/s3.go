@@ -0,0 +1,610 @@
+package writers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Archiver takes over the finished segments of FileWriter, see WithArchiver.
+type Archiver interface {
+	// Archive queues a finished segment. The archiver owns the file afterwards,
+	// and removes it once it was archived. Queuing a segment twice is a no-op.
+	Archive(path string)
+}
+
+var _ Archiver = &S3Archiver{}
+
+// S3Archiver uploads finished segments to an S3-compatible object storage, and
+// removes the local copy only after the upload was verified.
+//
+// Segments larger than the part size are uploaded by multipart upload. Every
+// request carries a Content-MD5 and the returned ETags are checked against the
+// local checksums. Failed uploads are retried with backoff until Close.
+type S3Archiver struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	logger           *log.Logger
+	fs               FS
+	client           *http.Client
+	endpoint         *url.URL
+	bucket           string
+	region           string
+	accessKeyID      string
+	secretAccessKey  string
+	sessionToken     string
+	pathStyle        bool
+	keyTemplate      string
+	service          string
+	host             string
+	partSize         int64
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+	etagCheck        bool
+
+	mutex    sync.Mutex
+	queue    []string
+	known    map[string]int
+	notifyCh chan struct{}
+}
+
+// NewS3Archiver new an archiver uploading to the bucket at endpoint, e.g. `https://s3.us-east-1.amazonaws.com`.
+//
+//   - default object key :  {service}/{date}/{host}/{segment}
+//   - default part size  :  16MiB
+//   - default addressing :  path style
+func NewS3Archiver(endpoint, bucket string, opts ...S3ArchiverOption) (*S3Archiver, error) {
+	if bucket == "" {
+		return nil, errors.New("params bucket is required")
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("bad endpoint `%s`", endpoint)
+	}
+	host, _ := os.Hostname()
+
+	a := &S3Archiver{
+		logger:           log.New(io.Discard, "", log.LstdFlags),
+		fs:               OSFS(),
+		client:           http.DefaultClient,
+		endpoint:         u,
+		bucket:           bucket,
+		region:           "us-east-1",
+		pathStyle:        true,
+		keyTemplate:      "{service}/{date}/{host}/{segment}",
+		service:          "alog",
+		host:             host,
+		partSize:         16 * 1024 * 1024,
+		retryInterval:    time.Second,
+		maxRetryInterval: 5 * time.Minute,
+		etagCheck:        true,
+		known:            make(map[string]int),
+		notifyCh:         make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	a.wg.Add(1)
+	go a.setupUploadWorker()
+
+	return a, nil
+}
+
+func (a *S3Archiver) Archive(path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if _, ok := a.known[path]; ok || a.ctx.Err() != nil {
+		return
+	}
+	a.known[path] = 0
+	a.queue = append(a.queue, path)
+	a.notify()
+}
+
+// Close stops uploading and waits for the upload in progress to be aborted.
+// Segments not uploaded yet are kept, and they are retried by the next run.
+func (a *S3Archiver) Close() error {
+	a.cancel()
+	a.wg.Wait()
+	return nil
+}
+
+func (a *S3Archiver) notify() {
+	select {
+	case a.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+func (a *S3Archiver) setupUploadWorker() {
+	defer a.wg.Done()
+
+	for {
+		path, ok := a.next()
+		if !ok {
+			select {
+			case <-a.ctx.Done():
+				a.logger.Println("[I] upload worker exit")
+				return
+			case <-a.notifyCh:
+			}
+			continue
+		}
+
+		err := a.upload(a.ctx, path)
+		if err == nil {
+			if err := a.fs.Remove(path); err != nil {
+				a.logger.Printf("[E] remove archived file `%s` failed, %v\n", path, err)
+			}
+			a.logger.Printf("[D] archived file `%s`\n", path)
+			a.mutex.Lock()
+			delete(a.known, path)
+			a.mutex.Unlock()
+			continue
+		}
+		if a.ctx.Err() != nil {
+			continue
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			a.logger.Printf("[W] file `%s` to archive not found\n", path)
+			a.mutex.Lock()
+			delete(a.known, path)
+			a.mutex.Unlock()
+			continue
+		}
+		a.retryLater(path, err)
+	}
+}
+
+func (a *S3Archiver) next() (string, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if len(a.queue) == 0 || a.ctx.Err() != nil {
+		return "", false
+	}
+	path := a.queue[0]
+	a.queue = a.queue[1:]
+	return path, true
+}
+
+func (a *S3Archiver) retryLater(path string, err error) {
+	a.mutex.Lock()
+	attempts := a.known[path] + 1
+	a.known[path] = attempts
+	a.mutex.Unlock()
+
+	delay := a.retryInterval << (attempts - 1)
+	if delay > a.maxRetryInterval || delay <= 0 {
+		delay = a.maxRetryInterval
+	}
+	a.logger.Printf("[E] archive file `%s` failed, retry in %v, %v\n", path, delay, err)
+
+	time.AfterFunc(delay, func() {
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+		if a.ctx.Err() != nil {
+			return
+		}
+		a.queue = append(a.queue, path)
+		a.notify()
+	})
+}
+
+func (a *S3Archiver) upload(ctx context.Context, path string) error {
+	f, err := a.fs.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat file failed, %w", err)
+	}
+	key := a.objectKey(info)
+
+	if info.Size() <= a.partSize {
+		body, err := io.ReadAll(f)
+		if err != nil {
+			return fmt.Errorf("read file failed, %w", err)
+		}
+		return a.putObject(ctx, key, body)
+	}
+	return a.multipartUpload(ctx, key, f)
+}
+
+var segmentDatePattern = regexp.MustCompile(`(^|-)(\d{8})-\d+`)
+
+// objectKey renders the key template. The date is taken from the segment filename,
+// or from the modification time if the filename does not carry one.
+func (a *S3Archiver) objectKey(info os.FileInfo) string {
+	date := info.ModTime().UTC().Format("20060102")
+	if m := segmentDatePattern.FindStringSubmatch(info.Name()); m != nil {
+		date = m[2]
+	}
+	return strings.NewReplacer(
+		"{service}", a.service,
+		"{date}", date,
+		"{host}", a.host,
+		"{segment}", info.Name(),
+	).Replace(a.keyTemplate)
+}
+
+func (a *S3Archiver) putObject(ctx context.Context, key string, body []byte) error {
+	sum := md5.Sum(body)
+	resp, _, err := a.do(ctx, http.MethodPut, key, nil, body, sum[:])
+	if err != nil {
+		return fmt.Errorf("put object failed, %w", err)
+	}
+	return a.checkETag(resp.Header.Get("ETag"), hex.EncodeToString(sum[:]))
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (a *S3Archiver) multipartUpload(ctx context.Context, key string, r io.Reader) (err error) {
+	_, respBody, err := a.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		return fmt.Errorf("create multipart upload failed, %w", err)
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(respBody, &initiated); err != nil || initiated.UploadID == "" {
+		return fmt.Errorf("bad create multipart upload response, %v", err)
+	}
+	defer func() {
+		if err != nil {
+			// the abort outlives a cancelled context, otherwise parts are left behind
+			abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if _, _, abortErr := a.do(abortCtx, http.MethodDelete, key, url.Values{"uploadId": {initiated.UploadID}}, nil, nil); abortErr != nil {
+				a.logger.Printf("[E] abort multipart upload of `%s` failed, %v\n", key, abortErr)
+			}
+		}
+	}()
+
+	var parts []completedPart
+	var partSums []byte
+	buf := make([]byte, a.partSize)
+	for partNumber := 1; ; partNumber++ {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil && readErr != io.ErrUnexpectedEOF {
+			return fmt.Errorf("read file failed, %w", readErr)
+		}
+
+		sum := md5.Sum(buf[:n])
+		query := url.Values{
+			"partNumber": {strconv.Itoa(partNumber)},
+			"uploadId":   {initiated.UploadID},
+		}
+		resp, _, err := a.do(ctx, http.MethodPut, key, query, buf[:n], sum[:])
+		if err != nil {
+			return fmt.Errorf("upload part %d failed, %w", partNumber, err)
+		}
+		etag := resp.Header.Get("ETag")
+		if err := a.checkETag(etag, hex.EncodeToString(sum[:])); err != nil {
+			return fmt.Errorf("upload part %d failed, %w", partNumber, err)
+		}
+		parts = append(parts, completedPart{PartNumber: partNumber, ETag: etag})
+		partSums = append(partSums, sum[:]...)
+
+		if readErr == io.ErrUnexpectedEOF {
+			break
+		}
+	}
+
+	completeBody, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return fmt.Errorf("marshal complete request failed, %w", err)
+	}
+	_, respBody, err = a.do(ctx, http.MethodPost, key, url.Values{"uploadId": {initiated.UploadID}}, completeBody, nil)
+	if err != nil {
+		return fmt.Errorf("complete multipart upload failed, %w", err)
+	}
+	var completed struct {
+		XMLName xml.Name
+		ETag    string `xml:"ETag"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.Unmarshal(respBody, &completed); err != nil {
+		return fmt.Errorf("bad complete multipart upload response, %w", err)
+	}
+	// S3 may report a failure of completing with status 200
+	if completed.XMLName.Local == "Error" {
+		return fmt.Errorf("complete multipart upload failed, %s", completed.Message)
+	}
+
+	sum := md5.Sum(partSums)
+	return a.checkETag(completed.ETag, fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), len(parts)))
+}
+
+func (a *S3Archiver) checkETag(etag, expected string) error {
+	if !a.etagCheck {
+		return nil
+	}
+	if etag = strings.Trim(etag, `"`); etag != expected {
+		return fmt.Errorf("checksum mismatch, expected etag %s, got %s", expected, etag)
+	}
+	return nil
+}
+
+// do sends a signed request and returns the response with its body read.
+// Responses with a non-2xx status are returned as an error.
+func (a *S3Archiver) do(ctx context.Context, method, key string, query url.Values, body, contentMD5 []byte) (*http.Response, []byte, error) {
+	u := *a.endpoint
+	escapedKey := s3EscapePath(key)
+	if a.pathStyle {
+		u.Path = strings.TrimSuffix(a.endpoint.Path, "/") + "/" + a.bucket + "/" + key
+		u.RawPath = strings.TrimSuffix(a.endpoint.EscapedPath(), "/") + "/" + s3EscapePath(a.bucket) + "/" + escapedKey
+	} else {
+		u.Host = a.bucket + "." + u.Host
+		u.Path = "/" + key
+		u.RawPath = "/" + escapedKey
+	}
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if contentMD5 != nil {
+		req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(contentMD5))
+	}
+	if a.accessKeyID != "" {
+		a.sign(req, body, time.Now())
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read response failed, %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(respBody) > 512 {
+			respBody = respBody[:512]
+		}
+		return nil, nil, fmt.Errorf("unexpected status %s, %s", resp.Status, bytes.TrimSpace(respBody))
+	}
+	return resp, respBody, nil
+}
+
+// sign signs the request with AWS Signature Version 4.
+func (a *S3Archiver) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := amzDate[:8] + "/" + a.region + "/s3/aws4_request"
+	payloadHash := sha256.Sum256(body)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	if a.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", a.sessionToken)
+	}
+
+	var signedHeaders []string
+	for name := range req.Header {
+		signedHeaders = append(signedHeaders, strings.ToLower(name))
+	}
+	sort.Strings(signedHeaders)
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+a.secretAccessKey), amzDate[:8])
+	signingKey = hmacSHA256(signingKey, a.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		a.accessKeyID, scope, strings.Join(signedHeaders, ";"),
+		hex.EncodeToString(hmacSHA256(signingKey, stringToSign)),
+	))
+	req.Header.Del("Host")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape escapes s as RFC 3986 requires, which is what signature V4 expects.
+func s3Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func s3EscapePath(s string) string {
+	segments := strings.Split(s, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			pairs = append(pairs, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+type S3ArchiverOption func(a *S3Archiver)
+
+// WithS3Credentials signs requests with the given credentials. Requests are sent
+// anonymously without credentials. sessionToken is optional.
+func WithS3Credentials(accessKeyID, secretAccessKey, sessionToken string) S3ArchiverOption {
+	return func(a *S3Archiver) {
+		a.accessKeyID = accessKeyID
+		a.secretAccessKey = secretAccessKey
+		a.sessionToken = sessionToken
+	}
+}
+
+// WithS3Region sets the region requests are signed for. Default is us-east-1.
+func WithS3Region(v string) S3ArchiverOption {
+	return func(a *S3Archiver) {
+		if v = strings.TrimSpace(v); v != "" {
+			a.region = v
+		}
+	}
+}
+
+// WithS3PathStyle decides whether the bucket is addressed by path or by virtual host.
+func WithS3PathStyle(v bool) S3ArchiverOption {
+	return func(a *S3Archiver) {
+		a.pathStyle = v
+	}
+}
+
+// WithS3KeyTemplate sets the object key template.
+// Supported placeholders are {service}, {date}, {host} and {segment}.
+func WithS3KeyTemplate(v string) S3ArchiverOption {
+	return func(a *S3Archiver) {
+		if v = strings.TrimSpace(v); v != "" {
+			a.keyTemplate = strings.TrimPrefix(v, "/")
+		}
+	}
+}
+
+// WithS3Service sets the {service} of object keys. Default is alog.
+func WithS3Service(v string) S3ArchiverOption {
+	return func(a *S3Archiver) {
+		if v = strings.TrimSpace(v); v != "" {
+			a.service = v
+		}
+	}
+}
+
+// WithS3Host sets the {host} of object keys. Default is the hostname.
+func WithS3Host(v string) S3ArchiverOption {
+	return func(a *S3Archiver) {
+		if v = strings.TrimSpace(v); v != "" {
+			a.host = v
+		}
+	}
+}
+
+// WithS3PartSize sets the size of multipart upload parts, segments no larger than
+// it are uploaded by a single request. S3 requires parts of at least 5MiB.
+func WithS3PartSize(v int64) S3ArchiverOption {
+	return func(a *S3Archiver) {
+		if v > 0 {
+			a.partSize = v
+		}
+	}
+}
+
+// WithS3RetryInterval sets the backoff of failed uploads, which doubles on every
+// attempt up to max.
+func WithS3RetryInterval(v, max time.Duration) S3ArchiverOption {
+	return func(a *S3Archiver) {
+		if v > 0 {
+			a.retryInterval = v
+		}
+		if max >= a.retryInterval {
+			a.maxRetryInterval = max
+		}
+	}
+}
+
+// WithS3ETagCheck decides whether returned ETags are checked against the MD5 of the
+// uploaded data. Disable it for storages whose ETags are not MD5, e.g. SSE-KMS buckets;
+// Content-MD5 is still checked by the storage.
+func WithS3ETagCheck(v bool) S3ArchiverOption {
+	return func(a *S3Archiver) {
+		a.etagCheck = v
+	}
+}
+
+// WithS3HTTPClient sends requests by the given client instead of http.DefaultClient.
+func WithS3HTTPClient(v *http.Client) S3ArchiverOption {
+	return func(a *S3Archiver) {
+		if v != nil {
+			a.client = v
+		}
+	}
+}
+
+// WithS3FS reads segments from the given filesystem, which should be the one of the FileWriter.
+func WithS3FS(v FS) S3ArchiverOption {
+	return func(a *S3Archiver) {
+		if v != nil {
+			a.fs = v
+		}
+	}
+}
+
+// WithS3LogWriter sets the writer that the archiver reports its internal state to.
+func WithS3LogWriter(writer io.Writer) S3ArchiverOption {
+	return func(a *S3Archiver) {
+		if writer != nil {
+			a.logger.SetOutput(writer)
+		}
+	}
+}
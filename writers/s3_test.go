@@ -0,0 +1,295 @@
+package writers_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/csh0101/alog/alogtest"
+	"github.com/csh0101/alog/writers"
+)
+
+func TestS3Archiver_FileWriter(t *testing.T) {
+	s3 := newFakeS3(t)
+	clock := alogtest.NewClock(testStartTime)
+	fs := writers.NewMemFS(clock)
+
+	archiver := newTestArchiver(t, s3.URL, fs)
+	w, err := writers.NewFileWriter(
+		"/logs",
+		writers.WithFS(fs),
+		writers.WithClock(clock),
+		writers.WithFilePrefix("test"),
+		writers.WithFileExt(".log"),
+		writers.WithFileMaxSizeInBytes(50),
+		writers.WithArchiver(archiver),
+	)
+	if err != nil {
+		t.Fatalf("new file writer failed, %v", err)
+	}
+	defer w.Close()
+
+	for i := 0; i < 3; i++ {
+		if _, err := w.Write([]byte(fmt.Sprintf("Hello, this is an archiver test %d", i))); err != nil {
+			t.Fatalf("write failed, %v", err)
+		}
+	}
+
+	waitForFiles(t, fs, "/logs", []string{"test-20240101-0002.log"})
+	s3.requireObject(t, "svc/20240101/host1/test-20240101-0000.log", "Hello, this is an archiver test 0")
+	s3.requireObject(t, "svc/20240101/host1/test-20240101-0001.log", "Hello, this is an archiver test 1")
+}
+
+func TestS3Archiver_Restart(t *testing.T) {
+	s3 := newFakeS3(t)
+	clock := alogtest.NewClock(testStartTime)
+	fs := writers.NewMemFS(clock)
+
+	// segments left by the last run
+	if err := fs.MkdirAll("/logs", 0755); err != nil {
+		t.Fatalf("create dir failed, %v", err)
+	}
+	for i, name := range []string{"test-20231231-0000.log", "test-20240101-0000.log"} {
+		f, err := fs.OpenFile("/logs/"+name, os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatalf("create file failed, %v", err)
+		}
+		if _, err := f.Write([]byte("left by the last run " + strconv.Itoa(i))); err != nil {
+			t.Fatalf("write file failed, %v", err)
+		}
+		f.Close()
+	}
+
+	w, err := writers.NewFileWriter(
+		"/logs",
+		writers.WithFS(fs),
+		writers.WithClock(clock),
+		writers.WithFilePrefix("test"),
+		writers.WithFileExt(".log"),
+		writers.WithArchiver(newTestArchiver(t, s3.URL, fs)),
+	)
+	if err != nil {
+		t.Fatalf("new file writer failed, %v", err)
+	}
+	defer w.Close()
+
+	// the segment of today is appended to, the one of yesterday is archived
+	waitForFiles(t, fs, "/logs", []string{"test-20240101-0000.log"})
+	s3.requireObject(t, "svc/20231231/host1/test-20231231-0000.log", "left by the last run 0")
+}
+
+func TestS3Archiver_MultipartAndRetry(t *testing.T) {
+	s3 := newFakeS3(t)
+	s3.failures = 2
+
+	fs := writers.NewMemFS(nil)
+	if err := fs.MkdirAll("/logs", 0755); err != nil {
+		t.Fatalf("create dir failed, %v", err)
+	}
+	content := strings.Repeat("0123456789", 3) + "abcde"
+	f, err := fs.OpenFile("/logs/big.log", os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("create file failed, %v", err)
+	}
+	f.Write([]byte(content))
+	f.Close()
+
+	archiver := newTestArchiver(t, s3.URL, fs, writers.WithS3PartSize(10), writers.WithS3KeyTemplate("{service}/{segment}"))
+	archiver.Archive("/logs/big.log")
+
+	waitForFiles(t, fs, "/logs", []string{})
+	s3.requireObject(t, "svc/big.log", content)
+
+	s3.mutex.Lock()
+	defer s3.mutex.Unlock()
+	if s3.parts != 4 {
+		t.Fatalf("expected 4 parts uploaded, got %d", s3.parts)
+	}
+}
+
+func TestS3Archiver_ChecksumMismatch(t *testing.T) {
+	s3 := newFakeS3(t)
+	s3.corrupt = true
+
+	fs := writers.NewMemFS(nil)
+	if err := fs.MkdirAll("/logs", 0755); err != nil {
+		t.Fatalf("create dir failed, %v", err)
+	}
+	f, err := fs.OpenFile("/logs/test.log", os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("create file failed, %v", err)
+	}
+	f.Write([]byte("keep me"))
+	f.Close()
+
+	archiver := newTestArchiver(t, s3.URL, fs)
+	archiver.Archive("/logs/test.log")
+
+	// the upload is retried and the local copy is kept
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		s3.mutex.Lock()
+		requests := s3.requests
+		s3.mutex.Unlock()
+		if requests >= 3 {
+			break
+		}
+	}
+	archiver.Close()
+	if names := fileNames(t, fs, "/logs"); len(names) != 1 {
+		t.Fatalf("local copy must be kept on checksum mismatch, got %v", names)
+	}
+}
+
+func newTestArchiver(t *testing.T, endpoint string, fs writers.FS, opts ...writers.S3ArchiverOption) *writers.S3Archiver {
+	t.Helper()
+
+	archiver, err := writers.NewS3Archiver(endpoint, "bucket", append([]writers.S3ArchiverOption{
+		writers.WithS3Credentials("AKID", "SECRET", ""),
+		writers.WithS3Service("svc"),
+		writers.WithS3Host("host1"),
+		writers.WithS3RetryInterval(time.Millisecond, 10*time.Millisecond),
+		writers.WithS3FS(fs),
+	}, opts...)...)
+	if err != nil {
+		t.Fatalf("new archiver failed, %v", err)
+	}
+	t.Cleanup(func() { archiver.Close() })
+	return archiver
+}
+
+// fakeS3 implements the subset of the S3 API used by S3Archiver.
+type fakeS3 struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	objects  map[string][]byte
+	uploads  map[string]map[int][]byte
+	requests int
+	parts    int
+	failures int
+	corrupt  bool
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	s3 := &fakeS3{
+		objects: make(map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+	}
+	s3.Server = httptest.NewServer(http.HandlerFunc(s3.serveHTTP))
+	t.Cleanup(s3.Close)
+	return s3
+}
+
+func (s *fakeS3) requireObject(t *testing.T, key, content string) {
+	t.Helper()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if b, ok := s.objects[key]; !ok || string(b) != content {
+		keys := make([]string, 0, len(s.objects))
+		for k := range s.objects {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		t.Fatalf("object `%s` mismatch, got %q, objects %v", key, b, keys)
+	}
+}
+
+func (s *fakeS3) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests++
+	if s.failures > 0 {
+		s.failures--
+		http.Error(w, "<Error><Code>InternalError</Code></Error>", http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	payloadHash := sha256.Sum256(body)
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") ||
+		r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(payloadHash[:]) {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+		return
+	}
+	if contentMD5 := r.Header.Get("Content-MD5"); contentMD5 != "" {
+		sum := md5.Sum(body)
+		if contentMD5 != base64.StdEncoding.EncodeToString(sum[:]) {
+			http.Error(w, "<Error><Code>BadDigest</Code></Error>", http.StatusBadRequest)
+			return
+		}
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/bucket/")
+	if !ok {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+
+	switch {
+	case r.Method == http.MethodPut && uploadID == "":
+		s.objects[key] = body
+		w.Header().Set("ETag", s.etag(body))
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID = strconv.Itoa(len(s.uploads) + 1)
+		s.uploads[uploadID] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", uploadID)
+	case r.Method == http.MethodPut:
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		s.uploads[uploadID][partNumber] = body
+		s.parts++
+		w.Header().Set("ETag", s.etag(body))
+	case r.Method == http.MethodPost:
+		var complete struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			http.Error(w, "<Error><Code>MalformedXML</Code></Error>", http.StatusBadRequest)
+			return
+		}
+		var object, sums []byte
+		for _, part := range complete.Parts {
+			data := s.uploads[uploadID][part.PartNumber]
+			sum := md5.Sum(data)
+			object = append(object, data...)
+			sums = append(sums, sum[:]...)
+		}
+		delete(s.uploads, uploadID)
+		s.objects[key] = object
+		sum := md5.Sum(sums)
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><ETag>\"%s-%d\"</ETag></CompleteMultipartUploadResult>", hex.EncodeToString(sum[:]), len(complete.Parts))
+	case r.Method == http.MethodDelete:
+		delete(s.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "<Error><Code>NotImplemented</Code></Error>", http.StatusNotImplemented)
+	}
+}
+
+func (s *fakeS3) etag(body []byte) string {
+	if s.corrupt {
+		body = append(bytes.Clone(body), '!')
+	}
+	sum := md5.Sum(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}